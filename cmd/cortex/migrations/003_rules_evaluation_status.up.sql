@@ -0,0 +1,10 @@
+-- The health of each rule group of each tenant, as last reported by the
+-- ruler evaluating it.
+CREATE TABLE IF NOT EXISTS rules_evaluation_status (
+  owner_id text NOT NULL,
+  file text NOT NULL,
+  group_name text NOT NULL,
+  status jsonb NOT NULL,
+  updated_at timestamp with time zone not null default now(),
+  PRIMARY KEY (owner_id, file, group_name)
+);
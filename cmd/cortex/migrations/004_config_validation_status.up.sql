@@ -0,0 +1,3 @@
+-- The validation status of each config version set through the configs API,
+-- as computed when it was set.
+ALTER TABLE configs ADD COLUMN IF NOT EXISTS validation jsonb;
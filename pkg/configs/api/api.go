@@ -1,6 +1,10 @@
 package api
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -8,17 +12,29 @@ import (
 	"fmt"
 	"html/template"
 	"io"
+	"math"
 	"mime"
 	"net/http"
+	"net/url"
+	"path"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 
+	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/gorilla/mux"
 	amconfig "github.com/prometheus/alertmanager/config"
 	amtemplate "github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
+	"github.com/prometheus/prometheus/promql/parser"
+	"go.uber.org/atomic"
+	"golang.org/x/time/rate"
 
 	"github.com/cortexproject/cortex/pkg/configs/db"
 	"github.com/cortexproject/cortex/pkg/configs/userconfig"
@@ -35,6 +51,8 @@ var (
 // Config configures Configs API
 type Config struct {
 	Notifications NotificationsConfig `yaml:"notifications"`
+	Validation    ValidationConfig    `yaml:"validation"`
+	Scan          ScanConfig          `yaml:"scan"`
 }
 
 // NotificationsConfig configures Alertmanager notifications method.
@@ -43,10 +61,68 @@ type NotificationsConfig struct {
 	DisableWebHook bool `yaml:"disable_webhook"`
 }
 
+// ValidationConfig configures additional validation applied to posted configs.
+type ValidationConfig struct {
+	RuleGroupNamePattern      string `yaml:"rule_group_name_pattern"`
+	MaxAlertmanagerReloadCost int    `yaml:"max_alertmanager_reload_cost"`
+	MaxRuleExpressionLength   int    `yaml:"max_rule_expression_length"`
+	MaxRuleExpressionNodes    int    `yaml:"max_rule_expression_nodes"`
+	TemplateSampleDataFile    string `yaml:"template_sample_data_file"`
+	Strict                    bool   `yaml:"strict"`
+	MaxReportedErrors         int    `yaml:"max_reported_errors"`
+
+	TenantExemptions map[string][]string `yaml:"tenant_exemptions" doc:"nocli|description=Per-tenant list of validation checks to skip. The supported checks are alertmanager_config, email_notifications, webhook_notifications, alertmanager_reload_cost, rules, rule_group_names, rule_expressions, templates, external_labels and ineffective_continue."`
+}
+
+// ScanConfig configures the paginated scan of all configs used by background jobs.
+type ScanConfig struct {
+	RateLimit         float64       `yaml:"rate_limit"`
+	MaxPageSize       int           `yaml:"max_page_size"`
+	MaxForegroundWait time.Duration `yaml:"max_foreground_wait"`
+}
+
+const (
+	defaultScanMaxPageSize = 100
+
+	// foregroundPollInterval is how often a low priority scan checks whether
+	// the foreground requests it waits for are done.
+	foregroundPollInterval = 10 * time.Millisecond
+)
+
 // RegisterFlags adds the flags required to configure this to the given FlagSet.
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&cfg.Notifications.DisableEmail, "configs.notifications.disable-email", false, "Disable Email notifications for Alertmanager.")
 	f.BoolVar(&cfg.Notifications.DisableWebHook, "configs.notifications.disable-webhook", false, "Disable WebHook notifications for Alertmanager.")
+	f.StringVar(&cfg.Validation.RuleGroupNamePattern, "configs.validation.rule-group-name-pattern", "", "Regex that every rule group name must match. It is fully anchored. Example: 'team-.*'. Empty means no constraint.")
+	f.IntVar(&cfg.Validation.MaxAlertmanagerReloadCost, "configs.validation.max-alertmanager-reload-cost", 0, "Maximum estimated reload cost of an Alertmanager config. The estimate adds 1 per route, 1 per receiver, 5 per receiver integration, 2 per inhibit rule and 10 per template. 0 to disable.")
+	f.IntVar(&cfg.Validation.MaxRuleExpressionLength, "configs.validation.max-rule-expression-length", 0, "Maximum length, in characters, of a rule expression. 0 to disable.")
+	f.IntVar(&cfg.Validation.MaxRuleExpressionNodes, "configs.validation.max-rule-expression-nodes", 0, "Maximum number of PromQL syntax tree nodes of a rule expression, such as selectors, operators, function calls and literals. 0 to disable.")
+	f.StringVar(&cfg.Validation.TemplateSampleDataFile, "configs.validation.template-sample-data-file", "", "JSON file with the notification data, in the format of the Alertmanager webhook payload, that templates are executed against when a config is set with deep=true. Empty to use built-in sample data.")
+	f.BoolVar(&cfg.Validation.Strict, "configs.validation.strict", false, "Reject configs for which the validation reports warnings. Tenants can also opt into strict validation for their config only, with a '# cortex:strict' comment line in their Alertmanager config or rules files.")
+	f.IntVar(&cfg.Validation.MaxReportedErrors, "configs.validation.max-reported-errors", 10, "Maximum number of problems returned when a config is set with all_errors=true, along with the total number of problems found. 0 to return all of them.")
+	f.Float64Var(&cfg.Scan.RateLimit, "configs.scan.rate-limit", 1, "Maximum number of pages per second served by the configs scan endpoint, across all clients. 0 to disable the limit.")
+	f.IntVar(&cfg.Scan.MaxPageSize, "configs.scan.max-page-size", defaultScanMaxPageSize, "Maximum number of configs returned in a page of the configs scan endpoint, also used when no limit is requested.")
+	f.DurationVar(&cfg.Scan.MaxForegroundWait, "configs.scan.max-foreground-wait", time.Second, "Maximum time a low priority page of the configs scan waits for in-flight public configs API requests to complete before being served anyway. 0 to not wait.")
+}
+
+// Validate validates the config.
+func (cfg *Config) Validate() error {
+	if _, err := relabel.NewRegexp(cfg.Validation.RuleGroupNamePattern); err != nil {
+		return fmt.Errorf("invalid rule group name pattern: %w", err)
+	}
+	if cfg.Validation.MaxReportedErrors < 0 {
+		return errors.New("max reported errors must not be negative")
+	}
+	if cfg.Scan.RateLimit < 0 {
+		return errors.New("scan rate limit must not be negative")
+	}
+	if cfg.Scan.MaxPageSize <= 0 {
+		return errors.New("scan max page size must be positive")
+	}
+	if cfg.Scan.MaxForegroundWait < 0 {
+		return errors.New("scan max foreground wait must not be negative")
+	}
+	return validateTenantExemptions(cfg.Validation.TenantExemptions)
 }
 
 // API implements the configs api.
@@ -54,18 +130,59 @@ type API struct {
 	http.Handler
 	db  db.DB
 	cfg Config
+
+	ruleGroupNamePattern *relabel.Regexp
+
+	// scanLimiter throttles the configs scan, nil when unlimited.
+	scanLimiter *rate.Limiter
+	// foregroundRequests is the number of in-flight requests to the public
+	// endpoints, which the configs scan yields to.
+	foregroundRequests atomic.Int64
+
+	templateSampleData *amtemplate.Data
+
+	// AllowDuplicateKeys disables rejecting posted configs that define the
+	// same key twice, for legacy clients relying on the last value winning.
+	AllowDuplicateKeys bool
+
+	// ExternalLabels are the labels the ruler adds to all alerts. When set,
+	// Alertmanager configs are checked for routes that can't match the alerts
+	// generated by the tenant's rules.
+	ExternalLabels labels.Labels
 }
 
 // New creates a new API
-func New(database db.DB, cfg Config) *API {
+func New(database db.DB, cfg Config) (*API, error) {
 	a := &API{
 		db:  database,
 		cfg: cfg,
+
+		templateSampleData: defaultTemplateSampleData,
+	}
+	if cfg.Validation.RuleGroupNamePattern != "" {
+		re, err := relabel.NewRegexp(cfg.Validation.RuleGroupNamePattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule group name pattern: %w", err)
+		}
+		a.ruleGroupNamePattern = &re
+	}
+	if cfg.Validation.TemplateSampleDataFile != "" {
+		data, err := loadTemplateSampleData(cfg.Validation.TemplateSampleDataFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template sample data: %w", err)
+		}
+		a.templateSampleData = data
+	}
+	if cfg.Scan.MaxPageSize <= 0 {
+		a.cfg.Scan.MaxPageSize = defaultScanMaxPageSize
+	}
+	if cfg.Scan.RateLimit > 0 {
+		a.scanLimiter = rate.NewLimiter(rate.Limit(cfg.Scan.RateLimit), 1)
 	}
 	r := mux.NewRouter()
 	a.RegisterRoutes(r)
 	a.Handler = r
-	return a
+	return a, nil
 }
 
 func (a *API) admin(w http.ResponseWriter, r *http.Request) {
@@ -92,19 +209,57 @@ func (a *API) RegisterRoutes(r *mux.Router) {
 		// be used.
 		{"get_rules", "GET", "/api/prom/configs/rules", a.getConfig},
 		{"set_rules", "POST", "/api/prom/configs/rules", a.setConfig},
+		{"delete_rules", "DELETE", "/api/prom/configs/rules", a.deleteConfig},
+		{"get_effective_rules", "GET", "/api/prom/configs/rules/effective", a.getEffectiveConfig},
+		{"validate_rules", "POST", "/api/prom/configs/rules/validate", a.validateRulesConfig},
+		{"get_rules_evaluation_status", "GET", "/api/prom/configs/rules/evaluation-status", a.getRulesEvaluationStatus},
+		{"compare_config_structure", "POST", "/api/prom/configs/rules/compare-structure", a.compareConfigStructure},
 		{"get_templates", "GET", "/api/prom/configs/templates", a.getConfig},
 		{"set_templates", "POST", "/api/prom/configs/templates", a.setConfig},
 		{"get_alertmanager_config", "GET", "/api/prom/configs/alertmanager", a.getConfig},
+		{"get_all_configs", "GET", "/api/prom/configs/all", a.getConfigBundle},
 		{"set_alertmanager_config", "POST", "/api/prom/configs/alertmanager", a.setConfig},
+		{"delete_alertmanager_config", "DELETE", "/api/prom/configs/alertmanager", a.deleteConfig},
 		{"validate_alertmanager_config", "POST", "/api/prom/configs/alertmanager/validate", a.validateAlertmanagerConfig},
+		{"get_orphaned_templates", "GET", "/api/prom/configs/alertmanager/orphaned-templates", a.getOrphanedTemplates},
 		{"deactivate_config", "DELETE", "/api/prom/configs/deactivate", a.deactivateConfig},
 		{"restore_config", "POST", "/api/prom/configs/restore", a.restoreConfig},
 		// Internal APIs.
 		{"private_get_rules", "GET", "/private/api/prom/configs/rules", a.getConfigs},
 		{"private_get_alertmanager_config", "GET", "/private/api/prom/configs/alertmanager", a.getConfigs},
+		{"private_get_template_function_usage", "GET", "/private/api/prom/configs/alertmanager/template-function-usage", a.getTemplateFunctionUsage},
+		{"private_merge_alertmanager_configs", "POST", "/private/api/prom/configs/alertmanager/merge", a.mergeAlertmanagerConfigs},
+		{"private_export_all_configs", "GET", "/private/api/prom/configs/export-all", a.exportAllConfigs},
+		{"private_compare_config_structure", "POST", "/private/api/prom/configs/rules/compare-structure", a.privateCompareConfigStructure},
+		{"private_scan_rules", "GET", "/private/api/prom/configs/rules/scan", a.scanConfigs},
+		{"private_set_rules_evaluation_status", "POST", "/private/api/prom/configs/rules/evaluation-status", a.setRulesEvaluationStatus},
+		{"private_get_rules_churn", "GET", "/private/api/prom/configs/rules/churn", a.getRulesChurn},
 	} {
-		r.Handle(route.path, route.handler).Methods(route.method).Name(route.name)
+		handler := route.handler
+		if strings.HasPrefix(route.path, "/api/") {
+			handler = a.foreground(handler)
+		}
+		r.Handle(route.path, handler).Methods(route.method).Name(route.name)
+	}
+}
+
+// foreground tracks the in-flight requests of a handler serving live traffic.
+func (a *API) foreground(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a.foregroundRequests.Inc()
+		defer a.foregroundRequests.Dec()
+		h(w, r)
+	}
+}
+
+// activeConfig gets the config of a user. A deleted config is reported as
+// missing, with sql.ErrNoRows.
+func (a *API) activeConfig(ctx context.Context, userID string) (userconfig.View, error) {
+	cfg, err := a.db.GetConfig(ctx, userID)
+	if err == nil && cfg.IsDeleted() {
+		return userconfig.View{}, sql.ErrNoRows
 	}
+	return cfg, err
 }
 
 // getConfig returns the request configuration.
@@ -116,7 +271,67 @@ func (a *API) getConfig(w http.ResponseWriter, r *http.Request) {
 	}
 	logger := util_log.WithContext(r.Context(), util_log.Logger)
 
-	cfg, err := a.db.GetConfig(r.Context(), userID)
+	cfg, err := a.activeConfig(r.Context(), userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "No configuration", http.StatusNotFound)
+		return
+	} else if err != nil {
+		// XXX: Untested
+		level.Error(logger).Log("msg", "error getting config", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if includeValidation, _ := strconv.ParseBool(r.FormValue("include_validation")); includeValidation {
+		status, err := a.storedValidationStatus(r.Context(), logger, userID, cfg)
+		if err != nil {
+			// XXX: Untested
+			level.Error(logger).Log("msg", "error getting config validation status", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeConfig(w, r, ValidatedView{View: cfg, Validation: status})
+		return
+	}
+	writeConfig(w, r, cfg)
+}
+
+// getConfigBundle returns the rules, Alertmanager config and templates of the
+// requesting user at a single version. It returns 404 only when neither rules
+// nor an Alertmanager config exist.
+func (a *API) getConfigBundle(w http.ResponseWriter, r *http.Request) {
+	userID, _, err := tenant.ExtractTenantIDFromHTTPRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	logger := util_log.WithContext(r.Context(), util_log.Logger)
+
+	cfg, err := a.activeConfig(r.Context(), userID)
+	if err == sql.ErrNoRows || (err == nil && len(cfg.Config.RulesConfig.Files) == 0 && cfg.Config.AlertmanagerConfig == "") {
+		http.Error(w, "No configuration", http.StatusNotFound)
+		return
+	} else if err != nil {
+		// XXX: Untested
+		level.Error(logger).Log("msg", "error getting config", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeConfig(w, r, cfg)
+}
+
+// getEffectiveConfig returns the request configuration with all rule group
+// references resolved. The stored configuration is left untouched.
+func (a *API) getEffectiveConfig(w http.ResponseWriter, r *http.Request) {
+	userID, _, err := tenant.ExtractTenantIDFromHTTPRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	logger := util_log.WithContext(r.Context(), util_log.Logger)
+
+	cfg, err := a.activeConfig(r.Context(), userID)
 	if err == sql.ErrNoRows {
 		http.Error(w, "No configuration", http.StatusNotFound)
 		return
@@ -127,7 +342,24 @@ func (a *API) getConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	switch parseConfigFormat(r.Header.Get("Accept"), FormatJSON) {
+	cfg.Config.RulesConfig, err = cfg.Config.RulesConfig.ResolveRefs()
+	if err != nil {
+		level.Info(logger).Log("msg", "error resolving rule group references", "err", err)
+		http.Error(w, fmt.Sprintf("Invalid rules: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	writeConfig(w, r, cfg)
+}
+
+// writeConfig encodes the given config in the format requested by the
+// Accept header, defaulting to JSON. It responds with 406 when neither JSON
+// nor YAML is acceptable.
+func writeConfig(w http.ResponseWriter, r *http.Request, cfg interface{}) {
+	logger := util_log.WithContext(r.Context(), util_log.Logger)
+
+	var err error
+	switch acceptedConfigFormat(r.Header.Get("Accept")) {
 	case FormatJSON:
 		w.Header().Set("Content-Type", "application/json")
 		err = json.NewEncoder(w).Encode(cfg)
@@ -135,9 +367,7 @@ func (a *API) getConfig(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/yaml")
 		err = yaml.NewEncoder(w).Encode(cfg)
 	default:
-		// should never reach this point
-		level.Error(logger).Log("msg", "unexpected error detecting the config format")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Configs can only be returned as JSON or YAML", http.StatusNotAcceptable)
 	}
 	if err != nil {
 		// XXX: Untested
@@ -146,53 +376,93 @@ func (a *API) getConfig(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (a *API) setConfig(w http.ResponseWriter, r *http.Request) {
-	userID, _, err := tenant.ExtractTenantIDFromHTTPRequest(r)
+// decodeConfig decodes the config posted in the request body, in the format
+// given by its Content-Type. It writes an error response and returns false if
+// the body can't be decoded.
+func (a *API) decodeConfig(w http.ResponseWriter, r *http.Request, logger log.Logger) (userconfig.Config, bool) {
+	var cfg userconfig.Config
+	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
-		return
+		// XXX: Untested
+		level.Error(logger).Log("msg", "error reading request body", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return cfg, false
 	}
-	logger := util_log.WithContext(r.Context(), util_log.Logger)
 
-	var cfg userconfig.Config
 	switch parseConfigFormat(r.Header.Get("Content-Type"), FormatJSON) {
 	case FormatJSON:
-		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		if !a.AllowDuplicateKeys {
+			if err := checkDuplicateJSONKeys(body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return cfg, false
+			}
+		}
+		if err := json.NewDecoder(bytes.NewReader(body)).Decode(&cfg); err != nil {
 			// XXX: Untested
 			level.Error(logger).Log("msg", "error decoding json body", "err", err)
 			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+			return cfg, false
 		}
 	case FormatYAML:
-		if err := yaml.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		if !a.AllowDuplicateKeys {
+			if err := checkDuplicateYAMLKeys(body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return cfg, false
+			}
+		}
+		if err := yaml.NewDecoder(bytes.NewReader(body)).Decode(&cfg); err != nil {
 			// XXX: Untested
 			level.Error(logger).Log("msg", "error decoding yaml body", "err", err)
 			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+			return cfg, false
 		}
 	default:
 		// should never reach this point
 		level.Error(logger).Log("msg", "unexpected error detecting the config format")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		http.Error(w, "unexpected config format", http.StatusInternalServerError)
+		return cfg, false
 	}
 
-	if err := validateAlertmanagerConfig(cfg.AlertmanagerConfig, a.cfg.Notifications); err != nil && cfg.AlertmanagerConfig != "" {
-		level.Error(logger).Log("msg", "invalid Alertmanager config", "err", err)
-		http.Error(w, fmt.Sprintf("Invalid Alertmanager config: %v", err), http.StatusBadRequest)
+	return cfg, true
+}
+
+func (a *API) setConfig(w http.ResponseWriter, r *http.Request) {
+	userID, _, err := tenant.ExtractTenantIDFromHTTPRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
-	if err := validateRulesFiles(cfg); err != nil {
-		level.Error(logger).Log("msg", "invalid rules", "err", err)
-		http.Error(w, fmt.Sprintf("Invalid rules: %v", err), http.StatusBadRequest)
+	logger := util_log.WithContext(r.Context(), util_log.Logger)
+
+	cfg, ok := a.decodeConfig(w, r, logger)
+	if !ok {
 		return
 	}
-	if err := validateTemplateFiles(cfg); err != nil {
-		level.Error(logger).Log("msg", "invalid templates", "err", err)
-		http.Error(w, fmt.Sprintf("Invalid templates: %v", err), http.StatusBadRequest)
-		return
+
+	ex := a.exemptionsFor(logger, userID)
+	deep, _ := strconv.ParseBool(r.FormValue("deep"))
+	if allErrors, _ := strconv.ParseBool(r.FormValue("all_errors")); allErrors {
+		if errs := a.validationErrors(cfg, ex, deep); len(errs) > 0 {
+			level.Error(logger).Log("msg", "invalid config", "errors", len(errs), "err", errs[0])
+			writeValidationErrors(w, errs, a.cfg.Validation.MaxReportedErrors)
+			return
+		}
+	} else {
+		if err := a.validateConfig(cfg, ex); err != nil {
+			level.Error(logger).Log("msg", "invalid config", "err", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if deep {
+			if err := executeTemplateFiles(cfg, a.templateSampleData); err != nil && !ex.skip(checkTemplates) {
+				level.Error(logger).Log("msg", "invalid templates", "err", err)
+				http.Error(w, configValidationError{part: "templates", err: err}.Error(), http.StatusBadRequest)
+				return
+			}
+		}
 	}
-	if err := a.db.SetConfig(r.Context(), userID, cfg); err != nil {
+	status := userconfig.ValidationStatus{Valid: true, Warnings: a.configWarnings(cfg, ex)}
+	if err := a.db.SetValidatedConfig(r.Context(), userID, cfg, status); err != nil {
 		// XXX: Untested
 		level.Error(logger).Log("msg", "error storing config", "err", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -201,6 +471,61 @@ func (a *API) setConfig(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// configValidationError is the failure of a validation check of the given part
+// of a config.
+type configValidationError struct {
+	part string
+	err  error
+}
+
+func (e configValidationError) Error() string {
+	return fmt.Sprintf("Invalid %s: %v", e.part, e.err)
+}
+
+// validateConfig runs all the validation checks of a posted config, skipping
+// the failed checks the tenant is exempt from.
+func (a *API) validateConfig(cfg userconfig.Config, ex exemptions) error {
+	if err := validateAlertmanagerConfig(cfg.AlertmanagerConfig, len(cfg.TemplateFiles), a.cfg, ex); err != nil && cfg.AlertmanagerConfig != "" {
+		return configValidationError{part: "Alertmanager config", err: err}
+	}
+	if err := a.validateRules(cfg, ex); err != nil {
+		return err
+	}
+	if err := validateTemplateFiles(cfg); err != nil && !ex.skip(checkTemplates) {
+		return configValidationError{part: "templates", err: err}
+	}
+	return a.validateStrict(cfg, ex)
+}
+
+// validateRules runs the validation checks of the rules of a posted config.
+func (a *API) validateRules(cfg userconfig.Config, ex exemptions) error {
+	if err := validateRulesConfig(cfg); err != nil {
+		if ex.skip(checkRules) {
+			// The other rules checks can't run on rules which don't parse.
+			return nil
+		}
+		return configValidationError{part: "rules", err: err}
+	}
+	if err := validateRuleGroupNames(cfg, a.ruleGroupNamePattern); err != nil && !ex.skip(checkRuleGroupNames) {
+		return configValidationError{part: "rules", err: err}
+	}
+	if err := validateRuleExpressions(cfg, a.cfg.Validation.MaxRuleExpressionLength, a.cfg.Validation.MaxRuleExpressionNodes); err != nil && !ex.skip(checkRuleExpressions) {
+		return configValidationError{part: "rules", err: err}
+	}
+	return nil
+}
+
+// validateStrict fails when strict validation applies to a config and it has
+// warnings.
+func (a *API) validateStrict(cfg userconfig.Config, ex exemptions) error {
+	if a.isStrict(cfg.AlertmanagerConfig, &cfg.RulesConfig) {
+		if warnings := a.configWarnings(cfg, ex); len(warnings) > 0 {
+			return configValidationError{part: "Alertmanager config", err: strictValidationError(warnings)}
+		}
+	}
+	return nil
+}
+
 func (a *API) validateAlertmanagerConfig(w http.ResponseWriter, r *http.Request) {
 	logger := util_log.WithContext(r.Context(), util_log.Logger)
 	cfg, err := io.ReadAll(r.Body)
@@ -210,7 +535,24 @@ func (a *API) validateAlertmanagerConfig(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err = validateAlertmanagerConfig(string(cfg), a.cfg.Notifications); err != nil {
+	// The tenant is optional: when known, its exemptions are applied and its
+	// stored rules, if any, are used to cross-check the labels the
+	// Alertmanager config routes on.
+	var ex exemptions
+	var rulesCfg *userconfig.RulesConfig
+	if userID, _, err := tenant.ExtractTenantIDFromHTTPRequest(r); err == nil {
+		ex = a.exemptionsFor(logger, userID)
+		if view, err := a.activeConfig(r.Context(), userID); err == nil {
+			rulesCfg = &view.Config.RulesConfig
+		}
+	}
+
+	err = validateAlertmanagerConfig(string(cfg), 0, a.cfg, ex)
+	warnings := alertmanagerConfigWarnings(string(cfg), rulesCfg, a.ExternalLabels, ex)
+	if err == nil && len(warnings) > 0 && a.isStrict(string(cfg), rulesCfg) {
+		err = strictValidationError(warnings)
+	}
+	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		util.WriteJSONResponse(w, map[string]string{
 			"status": "error",
@@ -219,130 +561,913 @@ func (a *API) validateAlertmanagerConfig(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	util.WriteJSONResponse(w, map[string]string{
+	resp := map[string]interface{}{
 		"status": "success",
-	})
+	}
+	if len(warnings) > 0 {
+		resp["warnings"] = warnings
+	}
+	util.WriteJSONResponse(w, resp)
 }
 
-func validateAlertmanagerConfig(cfg string, noCfg NotificationsConfig) error {
+// validateAlertmanagerConfig validates an Alertmanager config, which is
+// uploaded along with the given number of template files.
+func validateAlertmanagerConfig(cfg string, templateFiles int, apiCfg Config, ex exemptions) error {
 	amCfg, err := amconfig.Load(cfg)
 	if err != nil {
+		if ex.skip(checkAlertmanagerConfig) {
+			// None of the other checks can run on an unparsable config.
+			return nil
+		}
 		return err
 	}
 
+	noCfg := apiCfg.Notifications
 	for _, recv := range amCfg.Receivers {
-		if noCfg.DisableEmail && len(recv.EmailConfigs) > 0 {
+		if noCfg.DisableEmail && len(recv.EmailConfigs) > 0 && !ex.skip(checkEmailNotifications) {
 			return ErrEmailNotificationsAreDisabled
 		}
-		if noCfg.DisableWebHook && len(recv.WebhookConfigs) > 0 {
+		if noCfg.DisableWebHook && len(recv.WebhookConfigs) > 0 && !ex.skip(checkWebhookNotifications) {
 			return ErrWebhookNotificationsAreDisabled
 		}
 	}
 
+	if maxCost := apiCfg.Validation.MaxAlertmanagerReloadCost; maxCost > 0 {
+		if cost := alertmanagerReloadCost(amCfg, templateFiles); cost > maxCost && !ex.skip(checkAlertmanagerReloadCost) {
+			return fmt.Errorf("estimated Alertmanager reload cost %d exceeds the maximum of %d", cost, maxCost)
+		}
+	}
+
 	return nil
 }
 
-func validateRulesFiles(c userconfig.Config) error {
-	_, err := c.RulesConfig.Parse()
-	return err
+// alertmanagerConfigWarnings returns the warnings of the lints applied to a
+// valid Alertmanager config. The tenant's rules config is optional.
+func alertmanagerConfigWarnings(cfg string, rulesCfg *userconfig.RulesConfig, externalLabels labels.Labels, ex exemptions) []string {
+	amCfg, err := amconfig.Load(cfg)
+	if err != nil {
+		return nil
+	}
+	var warnings []string
+	if w := externalLabelsWarnings(amCfg, rulesCfg, externalLabels); len(w) > 0 && !ex.skip(checkExternalLabels) {
+		warnings = append(warnings, w...)
+	}
+	if w := ineffectiveContinueWarnings(amCfg); len(w) > 0 && !ex.skip(checkIneffectiveContinue) {
+		warnings = append(warnings, w...)
+	}
+	return warnings
 }
 
-func validateTemplateFiles(c userconfig.Config) error {
-	for fn, content := range c.TemplateFiles {
-		if _, err := template.New(fn).Funcs(template.FuncMap(amtemplate.DefaultFuncs)).Parse(content); err != nil {
-			return err
-		}
-	}
+// Weights used to estimate the cost of reloading an Alertmanager config.
+const (
+	reloadCostPerRoute       = 1
+	reloadCostPerReceiver    = 1
+	reloadCostPerIntegration = 5
+	reloadCostPerInhibitRule = 2
+	reloadCostPerTemplate    = 10
+)
 
-	return nil
+// alertmanagerReloadCost returns a heuristic estimate of how expensive it is
+// for an Alertmanager to reload the given config. Templates are counted both
+// from the config's template globs and the uploaded template files.
+func alertmanagerReloadCost(amCfg *amconfig.Config, templateFiles int) int {
+	cost := countRoutes(amCfg.Route) * reloadCostPerRoute
+	cost += len(amCfg.InhibitRules) * reloadCostPerInhibitRule
+	cost += (len(amCfg.Templates) + templateFiles) * reloadCostPerTemplate
+	for _, recv := range amCfg.Receivers {
+		integrations := len(recv.DiscordConfigs) + len(recv.EmailConfigs) + len(recv.PagerdutyConfigs) +
+			len(recv.SlackConfigs) + len(recv.WebhookConfigs) + len(recv.OpsGenieConfigs) +
+			len(recv.WechatConfigs) + len(recv.PushoverConfigs) + len(recv.VictorOpsConfigs) +
+			len(recv.SNSConfigs) + len(recv.TelegramConfigs) + len(recv.WebexConfigs) + len(recv.MSTeamsConfigs)
+		cost += reloadCostPerReceiver + integrations*reloadCostPerIntegration
+	}
+	return cost
 }
 
-// ConfigsView renders multiple configurations, mapping userID to userconfig.View.
-// Exposed only for tests.
-type ConfigsView struct {
-	Configs map[string]userconfig.View `json:"configs"`
+// countRoutes returns the number of routes in the tree rooted at r.
+func countRoutes(r *amconfig.Route) int {
+	if r == nil {
+		return 0
+	}
+	n := 1
+	for _, child := range r.Routes {
+		n += countRoutes(child)
+	}
+	return n
 }
 
-func (a *API) getConfigs(w http.ResponseWriter, r *http.Request) {
-	var cfgs map[string]userconfig.View
-	var cfgErr error
-	logger := util_log.WithContext(r.Context(), util_log.Logger)
-	rawSince := r.FormValue("since")
-	if rawSince == "" {
-		cfgs, cfgErr = a.db.GetAllConfigs(r.Context())
-	} else {
-		since, err := strconv.ParseUint(rawSince, 10, 0)
-		if err != nil {
-			level.Info(logger).Log("msg", "invalid config ID", "err", err)
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+// validateRulesConfig checks that the rules files of a config parse according
+// to its rule format version, and that the expression of every rule compiles.
+// Rules files in the Prometheus 2.x format are rejected when the config
+// declares the 1.x format, rather than being parsed as such.
+func validateRulesConfig(c userconfig.Config) error {
+	if c.RulesConfig.FormatVersion == userconfig.RuleFormatV1 {
+		for _, fn := range sortedKeys(c.RulesConfig.Files) {
+			if isV2RulesFile(c.RulesConfig.Files[fn]) {
+				return fmt.Errorf("%s is in the Prometheus 2.x rule format, but the rule format version is 1", fn)
+			}
 		}
-		cfgs, cfgErr = a.db.GetConfigs(r.Context(), userconfig.ID(since))
 	}
+	_, err := c.RulesConfig.Parse()
+	return err
+}
 
-	if cfgErr != nil {
-		// XXX: Untested
-		level.Error(logger).Log("msg", "error getting configs", "err", cfgErr)
-		http.Error(w, cfgErr.Error(), http.StatusInternalServerError)
-		return
+// isV2RulesFile tells whether the content of a rules file is a YAML document
+// listing rule groups, as in the Prometheus 2.x rule format.
+func isV2RulesFile(content string) bool {
+	var file map[string]interface{}
+	if err := yaml.Unmarshal([]byte(content), &file); err != nil {
+		return false
 	}
+	_, ok := file["groups"]
+	return ok
+}
 
-	view := ConfigsView{Configs: cfgs}
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(view); err != nil {
-		// XXX: Untested
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+func (a *API) validateRulesConfig(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), util_log.Logger)
+	cfg, ok := a.decodeConfig(w, r, logger)
+	if !ok {
 		return
 	}
-}
 
-func (a *API) deactivateConfig(w http.ResponseWriter, r *http.Request) {
-	userID, _, err := tenant.ExtractTenantIDFromHTTPRequest(r)
+	// The tenant is optional: when known, its exemptions are applied. The same
+	// rules checks as when setting the rules are run.
+	var ex exemptions
+	if userID, _, err := tenant.ExtractTenantIDFromHTTPRequest(r); err == nil {
+		ex = a.exemptionsFor(logger, userID)
+	}
+
+	err := a.validateRules(cfg, ex)
+	if err == nil {
+		err = a.validateStrict(cfg, ex)
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		w.WriteHeader(http.StatusBadRequest)
+		util.WriteJSONResponse(w, map[string]string{
+			"status": "error",
+			"error":  err.Error(),
+		})
 		return
 	}
-	logger := util_log.WithContext(r.Context(), util_log.Logger)
 
-	if err := a.db.DeactivateConfig(r.Context(), userID); err != nil {
-		if err == sql.ErrNoRows {
-			level.Info(logger).Log("msg", "deactivate config - no configuration", "userID", userID)
-			http.Error(w, "No configuration", http.StatusNotFound)
-			return
-		}
-		level.Error(logger).Log("msg", "error deactivating config", "err", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	util.WriteJSONResponse(w, map[string]string{
+		"status": "success",
+	})
+}
+
+// validateRuleGroupNames checks that every rule group name matches the
+// operator-configured pattern. A nil pattern means no constraint.
+func validateRuleGroupNames(c userconfig.Config, pattern *relabel.Regexp) error {
+	violators, err := ruleGroupNameViolations(c, pattern)
+	if err != nil || len(violators) == 0 {
+		return err
 	}
-	level.Info(logger).Log("msg", "config deactivated", "userID", userID)
-	w.WriteHeader(http.StatusOK)
+	return fmt.Errorf("rule group names must match %q: %s", pattern.String(), strings.Join(violators, ", "))
 }
 
-func (a *API) restoreConfig(w http.ResponseWriter, r *http.Request) {
-	userID, _, err := tenant.ExtractTenantIDFromHTTPRequest(r)
+// ruleGroupNameViolations returns the sorted list of rule groups whose name
+// doesn't match the pattern.
+func ruleGroupNameViolations(c userconfig.Config, pattern *relabel.Regexp) ([]string, error) {
+	if pattern == nil || len(c.RulesConfig.Files) == 0 {
+		return nil, nil
+	}
+	rgs, err := c.RulesConfig.ParseFormatted()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
-		return
+		return nil, err
 	}
-	logger := util_log.WithContext(r.Context(), util_log.Logger)
 
-	if err := a.db.RestoreConfig(r.Context(), userID); err != nil {
-		if err == sql.ErrNoRows {
-			level.Info(logger).Log("msg", "restore config - no configuration", "userID", userID)
-			http.Error(w, "No configuration", http.StatusNotFound)
-			return
+	var violators []string
+	for fn, groups := range rgs {
+		for _, rg := range groups.Groups {
+			if !pattern.MatchString(rg.Name) {
+				violators = append(violators, fmt.Sprintf("%q (%s)", rg.Name, fn))
+			}
 		}
-		level.Error(logger).Log("msg", "error restoring config", "err", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
 	}
-	level.Info(logger).Log("msg", "config restored", "userID", userID)
-	w.WriteHeader(http.StatusOK)
+	sort.Strings(violators)
+	return violators, nil
 }
 
-const (
-	FormatInvalid = "invalid"
-	FormatJSON    = "json"
+// validateRuleExpressions checks that no rule expression is longer than
+// maxLength characters or made of more than maxNodes PromQL AST nodes. A
+// limit of 0 disables the corresponding check.
+func validateRuleExpressions(c userconfig.Config, maxLength, maxNodes int) error {
+	violators, err := ruleExpressionViolations(c, maxLength, maxNodes)
+	if err != nil || len(violators) == 0 {
+		return err
+	}
+	return errors.New(strings.Join(violators, ", "))
+}
+
+// ruleExpressionViolations returns the sorted list of rules whose expression
+// exceeds one of the limits, along with the limit exceeded.
+func ruleExpressionViolations(c userconfig.Config, maxLength, maxNodes int) ([]string, error) {
+	if (maxLength <= 0 && maxNodes <= 0) || len(c.RulesConfig.Files) == 0 {
+		return nil, nil
+	}
+	rgs, err := c.RulesConfig.ParseFormatted()
+	if err != nil {
+		return nil, err
+	}
+
+	var violators []string
+	for fn, groups := range rgs {
+		for _, rg := range groups.Groups {
+			for _, rl := range rg.Rules {
+				name := rl.Record.Value
+				if rl.Alert.Value != "" {
+					name = rl.Alert.Value
+				}
+				rule := fmt.Sprintf("rule %q in group %q (%s)", name, rg.Name, fn)
+				if maxLength > 0 && len(rl.Expr.Value) > maxLength {
+					violators = append(violators, fmt.Sprintf("%s: expression length %d exceeds the maximum of %d", rule, len(rl.Expr.Value), maxLength))
+					continue
+				}
+				if maxNodes > 0 {
+					expr, err := parser.ParseExpr(rl.Expr.Value)
+					if err != nil {
+						return nil, err
+					}
+					if nodes := countExprNodes(expr); nodes > maxNodes {
+						violators = append(violators, fmt.Sprintf("%s: expression has %d nodes, exceeding the maximum of %d", rule, nodes, maxNodes))
+					}
+				}
+			}
+		}
+	}
+	sort.Strings(violators)
+	return violators, nil
+}
+
+func countExprNodes(expr parser.Expr) int {
+	nodes := 0
+	parser.Inspect(expr, func(parser.Node, []parser.Node) error {
+		nodes++
+		return nil
+	})
+	return nodes
+}
+
+func validateTemplateFiles(c userconfig.Config) error {
+	for fn, content := range c.TemplateFiles {
+		if _, err := parseTemplateFile(fn, content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func parseTemplateFile(fn, content string) (*template.Template, error) {
+	return template.New(fn).Funcs(template.FuncMap(amtemplate.DefaultFuncs)).Parse(content)
+}
+
+// OrphanedTemplatesView renders the template files which aren't referenced by
+// the Alertmanager config.
+// Exposed only for tests.
+type OrphanedTemplatesView struct {
+	OrphanedTemplates []string `json:"orphaned_templates"`
+}
+
+func (a *API) getOrphanedTemplates(w http.ResponseWriter, r *http.Request) {
+	userID, _, err := tenant.ExtractTenantIDFromHTTPRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	logger := util_log.WithContext(r.Context(), util_log.Logger)
+
+	cfg, err := a.activeConfig(r.Context(), userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "No configuration", http.StatusNotFound)
+		return
+	} else if err != nil {
+		// XXX: Untested
+		level.Error(logger).Log("msg", "error getting config", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	orphaned, err := orphanedTemplates(cfg.Config)
+	if err != nil {
+		level.Info(logger).Log("msg", "error looking for orphaned templates", "err", err)
+		http.Error(w, fmt.Sprintf("Invalid config: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	util.WriteJSONResponse(w, OrphanedTemplatesView{OrphanedTemplates: orphaned})
+}
+
+// compareConfigStructure compares the structure of the posted config against
+// the config of the tenant given by the `against` parameter, which defaults to
+// the authenticated tenant. Comparing against another tenant is only allowed
+// through the private endpoint.
+func (a *API) compareConfigStructure(w http.ResponseWriter, r *http.Request) {
+	userID, _, err := tenant.ExtractTenantIDFromHTTPRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	against := r.FormValue("against")
+	if against == "" {
+		against = userID
+	}
+	if against != userID {
+		http.Error(w, "comparing against another tenant's config requires admin access", http.StatusForbidden)
+		return
+	}
+	a.writeConfigStructureDiff(w, r, against)
+}
+
+func (a *API) privateCompareConfigStructure(w http.ResponseWriter, r *http.Request) {
+	against := r.FormValue("against")
+	if against == "" {
+		http.Error(w, "missing against parameter", http.StatusBadRequest)
+		return
+	}
+	a.writeConfigStructureDiff(w, r, against)
+}
+
+func (a *API) writeConfigStructureDiff(w http.ResponseWriter, r *http.Request, against string) {
+	logger := util_log.WithContext(r.Context(), util_log.Logger)
+
+	cfg, ok := a.decodeConfig(w, r, logger)
+	if !ok {
+		return
+	}
+	candidate, err := parseConfigStructure(cfg)
+	if err != nil {
+		level.Info(logger).Log("msg", "invalid candidate config", "err", err)
+		http.Error(w, fmt.Sprintf("Invalid config: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	ref, err := a.activeConfig(r.Context(), against)
+	if err == sql.ErrNoRows {
+		http.Error(w, "No configuration", http.StatusNotFound)
+		return
+	} else if err != nil {
+		// XXX: Untested
+		level.Error(logger).Log("msg", "error getting config", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	reference, err := parseConfigStructure(ref.Config)
+	if err != nil {
+		// XXX: Untested
+		level.Error(logger).Log("msg", "invalid stored config", "user", against, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	util.WriteJSONResponse(w, diffConfigStructure(candidate, reference))
+}
+
+// AlertmanagerMergeView renders the merge of the Alertmanager configs of two
+// tenants, and the conflicts found.
+// Exposed only for tests.
+type AlertmanagerMergeView struct {
+	AlertmanagerConfig string            `json:"alertmanager_config"`
+	TemplateFiles      map[string]string `json:"template_files,omitempty"`
+	Conflicts          []string          `json:"conflicts"`
+}
+
+// mergeAlertmanagerConfigs returns the merge of the Alertmanager configs of
+// the tenants given by the `a` and `b` parameters, following the rules of
+// mergeAlertmanagerConfigs. Nothing is stored.
+func (a *API) mergeAlertmanagerConfigs(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), util_log.Logger)
+
+	userA, userB := r.FormValue("a"), r.FormValue("b")
+	if userA == "" || userB == "" {
+		http.Error(w, "both the a and b tenants are required", http.StatusBadRequest)
+		return
+	}
+	if userA == userB {
+		http.Error(w, "the a and b tenants must be different", http.StatusBadRequest)
+		return
+	}
+
+	var cfgs []userconfig.Config
+	for _, userID := range []string{userA, userB} {
+		cfg, err := a.activeConfig(r.Context(), userID)
+		if err == sql.ErrNoRows || (err == nil && cfg.Config.AlertmanagerConfig == "") {
+			http.Error(w, fmt.Sprintf("No Alertmanager configuration for tenant %s", userID), http.StatusNotFound)
+			return
+		} else if err != nil {
+			// XXX: Untested
+			level.Error(logger).Log("msg", "error getting config", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		cfgs = append(cfgs, cfg.Config)
+	}
+
+	merged, conflicts, err := mergeAlertmanagerConfigs(userA, userB, cfgs[0], cfgs[1])
+	if err != nil {
+		level.Info(logger).Log("msg", "error merging Alertmanager configs", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if conflicts == nil {
+		conflicts = []string{}
+	}
+
+	util.WriteJSONResponse(w, AlertmanagerMergeView{
+		AlertmanagerConfig: merged.AlertmanagerConfig,
+		TemplateFiles:      merged.TemplateFiles,
+		Conflicts:          conflicts,
+	})
+}
+
+// ConfigsView renders multiple configurations, mapping userID to userconfig.View.
+// NextCursor is set when a page was requested and more configs follow, and is
+// to be passed as the since ID, or the scan cursor, of the next page.
+// Exposed only for tests.
+type ConfigsView struct {
+	Configs    map[string]userconfig.View `json:"configs"`
+	NextCursor *userconfig.ID             `json:"next_cursor,omitempty"`
+}
+
+// getConfigs returns the configs changed since a given ID, or all configs when
+// no ID is given. Deleted configs are only part of the full listing when
+// requested with deleted=true, while changes always include deletions so that
+// pollers see them.
+//
+// When a limit is given, only the configs with the lowest IDs are returned, up
+// to the limit, along with the cursor to get the next page from. Pages fetched
+// from a cursor are changes, so they include deletions as well.
+func (a *API) getConfigs(w http.ResponseWriter, r *http.Request) {
+	var cfgs map[string]userconfig.View
+	var cfgErr error
+	logger := util_log.WithContext(r.Context(), util_log.Logger)
+	limit := 0
+	if rawLimit := r.FormValue("limit"); rawLimit != "" {
+		l, err := strconv.Atoi(rawLimit)
+		if err != nil || l <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = l
+	}
+	var since *userconfig.ID
+	if rawSince := r.FormValue("since"); rawSince != "" {
+		s, err := strconv.ParseUint(rawSince, 10, 0)
+		if err != nil {
+			level.Info(logger).Log("msg", "invalid config ID", "err", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		id := userconfig.ID(s)
+		since = &id
+	}
+	includeDeleted, _ := strconv.ParseBool(r.FormValue("deleted"))
+	includeDeleted = includeDeleted || since != nil
+
+	switch {
+	case limit > 0:
+		// One more config tells whether there's a next page.
+		cfgs, cfgErr = a.db.GetConfigsPage(r.Context(), since, limit+1, includeDeleted)
+	case since == nil:
+		cfgs, cfgErr = a.db.GetAllConfigs(r.Context())
+	default:
+		cfgs, cfgErr = a.db.GetConfigs(r.Context(), *since)
+	}
+
+	if cfgErr != nil {
+		// XXX: Untested
+		level.Error(logger).Log("msg", "error getting configs", "err", cfgErr)
+		http.Error(w, cfgErr.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !includeDeleted {
+		cfgs = activeConfigs(cfgs)
+	}
+
+	view := ConfigsView{Configs: cfgs}
+	if limit > 0 {
+		view.Configs, view.NextCursor = firstConfigsByID(cfgs, limit)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(view); err != nil {
+		// XXX: Untested
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func activeConfigs(cfgs map[string]userconfig.View) map[string]userconfig.View {
+	active := make(map[string]userconfig.View, len(cfgs))
+	for userID, cfg := range cfgs {
+		if !cfg.IsDeleted() {
+			active[userID] = cfg
+		}
+	}
+	return active
+}
+
+// scanConfigs pages through all configs, including deleted ones, by ascending
+// ID. A config updated during a scan gets a new, higher, ID and is returned
+// again by a later page, so no config is missed.
+//
+// Pages are throttled by the scan rate limit: a 429 is returned with a
+// Retry-After header telling when to try again. Scans are low priority by
+// default and yield to live traffic, waiting for the in-flight requests to
+// complete for at most the max foreground wait. Pages requested with
+// priority=normal don't wait.
+func (a *API) scanConfigs(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), util_log.Logger)
+
+	var cursor *userconfig.ID
+	if rawCursor := r.FormValue("cursor"); rawCursor != "" {
+		c, err := strconv.ParseUint(rawCursor, 10, 0)
+		if err != nil {
+			level.Info(logger).Log("msg", "invalid scan cursor", "err", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		id := userconfig.ID(c)
+		cursor = &id
+	}
+	limit := a.cfg.Scan.MaxPageSize
+	if rawLimit := r.FormValue("limit"); rawLimit != "" {
+		l, err := strconv.Atoi(rawLimit)
+		if err != nil || l <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		if l < limit {
+			limit = l
+		}
+	}
+
+	var lowPriority bool
+	switch priority := r.FormValue("priority"); priority {
+	case "", "low":
+		lowPriority = true
+	case "normal":
+	default:
+		http.Error(w, fmt.Sprintf("unknown priority %q, expected low or normal", priority), http.StatusBadRequest)
+		return
+	}
+
+	if lowPriority {
+		if err := a.waitForeground(r.Context(), a.cfg.Scan.MaxForegroundWait); err != nil {
+			level.Info(logger).Log("msg", "scan canceled while yielding to foreground requests", "err", err)
+			return
+		}
+	}
+	if a.scanLimiter != nil {
+		res := a.scanLimiter.Reserve()
+		if delay := res.Delay(); delay > 0 {
+			res.Cancel()
+			writeRetryAfter(w, delay, "scan rate limit exceeded")
+			return
+		}
+	}
+
+	// One more config tells whether there's a next page.
+	cfgs, err := a.db.GetConfigsPage(r.Context(), cursor, limit+1, true)
+	if err != nil {
+		// XXX: Untested
+		level.Error(logger).Log("msg", "error getting configs", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	view := ConfigsView{}
+	view.Configs, view.NextCursor = firstConfigsByID(cfgs, limit)
+	util.WriteJSONResponse(w, view)
+}
+
+// waitForeground waits until no foreground request is in flight, for at most
+// maxWait. It only fails if the context is done first.
+func (a *API) waitForeground(ctx context.Context, maxWait time.Duration) error {
+	if maxWait <= 0 || a.foregroundRequests.Load() == 0 {
+		return nil
+	}
+	timer := time.NewTimer(maxWait)
+	defer timer.Stop()
+	ticker := time.NewTicker(foregroundPollInterval)
+	defer ticker.Stop()
+	for a.foregroundRequests.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			return nil
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// firstConfigsByID returns the limit configs with the lowest IDs, or all of
+// them for a limit <= 0. When more configs follow, it also returns the highest
+// ID returned, as the cursor from which to get the next ones.
+func firstConfigsByID(cfgs map[string]userconfig.View, limit int) (map[string]userconfig.View, *userconfig.ID) {
+	userIDs := make([]string, 0, len(cfgs))
+	for userID := range cfgs {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Slice(userIDs, func(i, j int) bool { return cfgs[userIDs[i]].ID < cfgs[userIDs[j]].ID })
+
+	var next *userconfig.ID
+	if limit > 0 && len(userIDs) > limit {
+		id := cfgs[userIDs[limit-1]].ID
+		next = &id
+		userIDs = userIDs[:limit]
+	}
+	first := make(map[string]userconfig.View, len(userIDs))
+	for _, userID := range userIDs {
+		first[userID] = cfgs[userID]
+	}
+	return first, next
+}
+
+func writeRetryAfter(w http.ResponseWriter, after time.Duration, msg string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(after.Seconds()))))
+	http.Error(w, msg, http.StatusTooManyRequests)
+}
+
+// TemplateFunctionUsageView renders, for each template function, the sorted
+// list of users whose templates call it.
+// Exposed only for tests.
+type TemplateFunctionUsageView struct {
+	Functions map[string][]string `json:"functions"`
+}
+
+func (a *API) getTemplateFunctionUsage(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), util_log.Logger)
+
+	cfgs, err := a.db.GetAllConfigs(r.Context())
+	if err != nil {
+		// XXX: Untested
+		level.Error(logger).Log("msg", "error getting configs", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	view := TemplateFunctionUsageView{Functions: map[string][]string{}}
+	for userID, cfg := range cfgs {
+		if cfg.IsDeleted() {
+			continue
+		}
+		funcs, err := templateFunctions(cfg.Config)
+		if err != nil {
+			level.Warn(logger).Log("msg", "skipping user with invalid templates", "userID", userID, "err", err)
+			continue
+		}
+		for name := range funcs {
+			view.Functions[name] = append(view.Functions[name], userID)
+		}
+	}
+	for _, users := range view.Functions {
+		sort.Strings(users)
+	}
+
+	util.WriteJSONResponse(w, view)
+}
+
+// exportAllConfigs streams a gzipped tarball of the configs of all users, with
+// a directory per user laid out as:
+//
+//	<user>/config.yml
+//	<user>/alertmanager.yml
+//	<user>/rules/<rules file>
+//	<user>/templates/<template file>
+//
+// config.yml holds the config settings not stored in files of their own, such
+// as the rule format version, so that the config can be restored exactly.
+// Deleted configs are not exported.
+func (a *API) exportAllConfigs(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), util_log.Logger)
+
+	cfgs, err := a.db.GetAllConfigs(r.Context())
+	if err != nil {
+		// XXX: Untested
+		level.Error(logger).Log("msg", "error getting configs", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	userIDs := make([]string, 0, len(cfgs))
+	for userID, cfg := range cfgs {
+		if !cfg.IsDeleted() {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	sort.Strings(userIDs)
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="configs.tar.gz"`)
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	modTime := time.Now()
+
+	// Once the first byte has been written, errors can only be logged.
+	for _, userID := range userIDs {
+		if err := writeConfigArchive(tw, userID, cfgs[userID].Config, modTime); err != nil {
+			level.Error(logger).Log("msg", "error exporting configs", "userID", userID, "err", err)
+			return
+		}
+	}
+	if err := tw.Close(); err != nil {
+		level.Error(logger).Log("msg", "error exporting configs", "err", err)
+		return
+	}
+	if err := gw.Close(); err != nil {
+		level.Error(logger).Log("msg", "error exporting configs", "err", err)
+	}
+}
+
+// archivedConfigSettings are the settings of a config written to config.yml in
+// the exported tarball.
+type archivedConfigSettings struct {
+	RuleFormatVersion userconfig.RuleFormatVersion `yaml:"rule_format_version"`
+}
+
+// writeConfigArchive writes the files of a user config to the tarball.
+func writeConfigArchive(tw *tar.Writer, userID string, cfg userconfig.Config, modTime time.Time) error {
+	dir := archiveFileName(userID)
+	settings, err := yaml.Marshal(archivedConfigSettings{RuleFormatVersion: cfg.RulesConfig.FormatVersion})
+	if err != nil {
+		return err
+	}
+	if err := writeArchiveFile(tw, path.Join(dir, "config.yml"), string(settings), modTime); err != nil {
+		return err
+	}
+	if cfg.AlertmanagerConfig != "" {
+		if err := writeArchiveFile(tw, path.Join(dir, "alertmanager.yml"), cfg.AlertmanagerConfig, modTime); err != nil {
+			return err
+		}
+	}
+	for _, files := range []struct {
+		dir   string
+		files map[string]string
+	}{
+		{"rules", cfg.RulesConfig.Files},
+		{"templates", cfg.TemplateFiles},
+	} {
+		names := make([]string, 0, len(files.files))
+		for name := range files.files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := writeArchiveFile(tw, path.Join(dir, files.dir, archiveFileName(name)), files.files[name], modTime); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeArchiveFile(tw *tar.Writer, name, content string, modTime time.Time) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(content)),
+		ModTime:  modTime,
+	}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(tw, content)
+	return err
+}
+
+// archiveFileName escapes a user ID or file name so that it's a single, non
+// hidden, path element in the tarball.
+func archiveFileName(name string) string {
+	name = url.PathEscape(name)
+	if strings.HasPrefix(name, ".") {
+		name = "%2E" + name[1:]
+	}
+	return name
+}
+
+// defaultChurnWindow is the window used by the rules churn endpoint when none
+// is given.
+const defaultChurnWindow = 24 * time.Hour
+
+// RulesChurnView renders, for each user, how many config versions were created
+// within the requested window. Users without new versions are omitted.
+// Exposed only for tests.
+type RulesChurnView struct {
+	Window   string         `json:"window"`
+	Versions map[string]int `json:"versions"`
+}
+
+func (a *API) getRulesChurn(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), util_log.Logger)
+
+	window := model.Duration(defaultChurnWindow)
+	if rawWindow := r.FormValue("window"); rawWindow != "" {
+		var err error
+		window, err = model.ParseDuration(rawWindow)
+		if err != nil {
+			level.Info(logger).Log("msg", "invalid churn window", "err", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if window <= 0 {
+			http.Error(w, "window must be positive", http.StatusBadRequest)
+			return
+		}
+	}
+
+	counts, err := a.db.GetConfigVersionCounts(r.Context(), time.Now().Add(-time.Duration(window)))
+	if err != nil {
+		// XXX: Untested
+		level.Error(logger).Log("msg", "error counting config versions", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	util.WriteJSONResponse(w, RulesChurnView{Window: window.String(), Versions: counts})
+}
+
+// deleteConfig marks the config of the requesting user deleted. The deletion
+// is stored as a new version of the config, so that it's seen by consumers
+// polling for changes, and setting a config again brings it back.
+func (a *API) deleteConfig(w http.ResponseWriter, r *http.Request) {
+	userID, _, err := tenant.ExtractTenantIDFromHTTPRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	logger := util_log.WithContext(r.Context(), util_log.Logger)
+
+	_, err = a.activeConfig(r.Context(), userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "No configuration", http.StatusNotFound)
+		return
+	} else if err != nil {
+		// XXX: Untested
+		level.Error(logger).Log("msg", "error getting config", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := a.db.DeactivateConfig(r.Context(), userID); err != nil {
+		// XXX: Untested
+		level.Error(logger).Log("msg", "error deleting config", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	level.Info(logger).Log("msg", "config deleted", "userID", userID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) deactivateConfig(w http.ResponseWriter, r *http.Request) {
+	userID, _, err := tenant.ExtractTenantIDFromHTTPRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	logger := util_log.WithContext(r.Context(), util_log.Logger)
+
+	if err := a.db.DeactivateConfig(r.Context(), userID); err != nil {
+		if err == sql.ErrNoRows {
+			level.Info(logger).Log("msg", "deactivate config - no configuration", "userID", userID)
+			http.Error(w, "No configuration", http.StatusNotFound)
+			return
+		}
+		level.Error(logger).Log("msg", "error deactivating config", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	level.Info(logger).Log("msg", "config deactivated", "userID", userID)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (a *API) restoreConfig(w http.ResponseWriter, r *http.Request) {
+	userID, _, err := tenant.ExtractTenantIDFromHTTPRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	logger := util_log.WithContext(r.Context(), util_log.Logger)
+
+	if err := a.db.RestoreConfig(r.Context(), userID); err != nil {
+		if err == sql.ErrNoRows {
+			level.Info(logger).Log("msg", "restore config - no configuration", "userID", userID)
+			http.Error(w, "No configuration", http.StatusNotFound)
+			return
+		}
+		level.Error(logger).Log("msg", "error restoring config", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	level.Info(logger).Log("msg", "config restored", "userID", userID)
+	w.WriteHeader(http.StatusOK)
+}
+
+const (
+	FormatInvalid = "invalid"
+	FormatJSON    = "json"
 	FormatYAML    = "yaml"
 )
 
@@ -365,3 +1490,59 @@ func parseConfigFormat(v string, defaultFormat string) string {
 	}
 	return defaultFormat
 }
+
+// acceptedConfigFormat returns the format to return a config in for the given
+// Accept header: the one of JSON and YAML it prefers, by quality value then by
+// order, JSON when it accepts any type or is empty, and FormatInvalid when it
+// accepts neither. Types with a quality value of 0 are never returned.
+func acceptedConfigFormat(accept string) string {
+	if accept == "" {
+		return FormatJSON
+	}
+	type acceptedFormats struct {
+		formats []string
+		q       float64
+	}
+	var accepted []acceptedFormats
+	refused := map[string]bool{}
+	for _, part := range strings.Split(accept, ",") {
+		mimeType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if rawQ, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(rawQ, 64); err != nil {
+				continue
+			}
+		}
+		var formats []string
+		switch mimeType {
+		case "*/*", "application/*":
+			formats = []string{FormatJSON, FormatYAML}
+		case "text/*":
+			formats = []string{FormatYAML}
+		default:
+			if format := parseConfigFormat(mimeType, FormatInvalid); format != FormatInvalid {
+				formats = []string{format}
+			}
+		}
+		if q <= 0 {
+			// Refusing a type overrides accepting it through a wildcard.
+			if len(formats) == 1 {
+				refused[formats[0]] = true
+			}
+			continue
+		}
+		accepted = append(accepted, acceptedFormats{formats: formats, q: q})
+	}
+	sort.SliceStable(accepted, func(i, j int) bool { return accepted[i].q > accepted[j].q })
+	for _, a := range accepted {
+		for _, format := range a.formats {
+			if !refused[format] {
+				return format
+			}
+		}
+	}
+	return FormatInvalid
+}
@@ -1,15 +1,29 @@
 package api
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
+
+	amconfig "github.com/prometheus/alertmanager/config"
+	"github.com/prometheus/prometheus/model/labels"
+	"gopkg.in/yaml.v2"
 
 	"github.com/cortexproject/cortex/pkg/configs/userconfig"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -223,6 +237,191 @@ func Test_GetConfigs_IncludesNewerConfigsAndExcludesOlder(t *testing.T) {
 	}
 }
 
+func Test_DeleteConfig(t *testing.T) {
+	for _, c := range allClients {
+		testDeleteConfig(t, c)
+	}
+}
+
+func testDeleteConfig(t *testing.T, c configurable) {
+	setup(t)
+	defer cleanup(t)
+
+	userID := makeUserID()
+	w := requestAsUser(t, userID, "DELETE", c.Endpoint, "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	created := c.post(t, userID, makeConfig())
+	otherUserID := makeUserID()
+	other := c.post(t, otherUserID, makeConfig())
+
+	w = requestAsUser(t, userID, "DELETE", c.Endpoint, "", nil)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	w = requestAsUser(t, userID, "GET", c.Endpoint, "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = requestAsUser(t, userID, "DELETE", c.Endpoint, "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// None of the endpoints reading the tenant's config find it anymore.
+	for _, req := range []struct {
+		method, url string
+	}{
+		{"GET", "/api/prom/configs/templates"},
+		{"GET", "/api/prom/configs/all"},
+		{"GET", "/api/prom/configs/rules/effective"},
+		{"GET", "/api/prom/configs/alertmanager/orphaned-templates"},
+		{"POST", "/api/prom/configs/rules/compare-structure"},
+		{"POST", "/private/api/prom/configs/rules/compare-structure?against=" + userID},
+		{"POST", "/private/api/prom/configs/alertmanager/merge?a=" + userID + "&b=" + otherUserID},
+		{"POST", "/private/api/prom/configs/alertmanager/merge?a=" + otherUserID + "&b=" + userID},
+	} {
+		w = requestAsUser(t, userID, req.method, req.url, "", readerFromConfig(t, makeConfig()))
+		assert.Equal(t, http.StatusNotFound, w.Code, "%s %s", req.method, req.url)
+	}
+
+	// The full listing excludes the deleted config, unless asked for.
+	w = request(t, "GET", c.PrivateEndpoint, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var found ConfigsView
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &found))
+	assert.Equal(t, map[string]userconfig.View{otherUserID: other}, found.Configs)
+
+	w = request(t, "GET", c.PrivateEndpoint+"?deleted=true", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	found = ConfigsView{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &found))
+	require.Contains(t, found.Configs, userID)
+	deleted := found.Configs[userID]
+	assert.True(t, deleted.IsDeleted())
+	assert.Equal(t, created.Config, deleted.Config)
+
+	// Pollers see the deletion as a newer version.
+	w = request(t, "GET", fmt.Sprintf("%s?since=%d", c.PrivateEndpoint, other.ID), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	found = ConfigsView{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &found))
+	assert.Equal(t, map[string]userconfig.View{userID: deleted}, found.Configs)
+	assert.Greater(t, deleted.ID, other.ID)
+
+	// Posting again brings the config back with a fresh ID.
+	resurrected := c.post(t, userID, makeConfig())
+	assert.False(t, resurrected.IsDeleted())
+	assert.Greater(t, resurrected.ID, deleted.ID)
+	w = request(t, "GET", fmt.Sprintf("%s?since=%d", c.PrivateEndpoint, deleted.ID), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	found = ConfigsView{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &found))
+	assert.Equal(t, map[string]userconfig.View{userID: resurrected}, found.Configs)
+}
+
+func Test_GetConfigs_Limit(t *testing.T) {
+	setup(t)
+	defer cleanup(t)
+
+	var userIDs []string
+	var views []userconfig.View
+	for i := 0; i < 7; i++ {
+		userID := makeUserID()
+		userIDs = append(userIDs, userID)
+		views = append(views, rulesClient.post(t, userID, makeConfig()))
+	}
+
+	// A deleted config, newer than all the others.
+	deletedUserID := makeUserID()
+	rulesClient.post(t, deletedUserID, makeConfig())
+	w := requestAsUser(t, deletedUserID, "DELETE", rulesEndpoint, "", nil)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	w = request(t, "GET", rulesPrivateEndpoint+"?deleted=true", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var all ConfigsView
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &all))
+	require.Contains(t, all.Configs, deletedUserID)
+
+	// Paging through the changes returns the deletion, as polling does.
+	for _, limit := range []int{1, 2, 3, 7, 10} {
+		limit := limit
+		t.Run(fmt.Sprintf("limit=%d", limit), func(t *testing.T) {
+			var found []string
+			since := views[0].ID
+			for pages := 0; ; pages++ {
+				require.Less(t, pages, 10, "paging doesn't terminate")
+				w := request(t, "GET", fmt.Sprintf("%s?limit=%d&since=%d", rulesPrivateEndpoint, limit, since), nil)
+				require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+				var page ConfigsView
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+				assert.LessOrEqual(t, len(page.Configs), limit)
+
+				// Pages hold the configs with the lowest IDs first.
+				var pageUserIDs []string
+				for _, userID := range append(append([]string{}, userIDs...), deletedUserID) {
+					if _, ok := page.Configs[userID]; ok {
+						pageUserIDs = append(pageUserIDs, userID)
+					}
+				}
+				require.Len(t, pageUserIDs, len(page.Configs))
+				found = append(found, pageUserIDs...)
+				if deleted, ok := page.Configs[deletedUserID]; ok {
+					assert.Equal(t, all.Configs[deletedUserID], deleted)
+				}
+				if page.NextCursor == nil {
+					break
+				}
+				assert.Equal(t, page.Configs[pageUserIDs[len(pageUserIDs)-1]].ID, *page.NextCursor)
+				since = *page.NextCursor
+			}
+			assert.Equal(t, append(append([]string{}, userIDs[1:]...), deletedUserID), found)
+		})
+	}
+
+	// The first page of the full listing leaves the deleted config out, unless
+	// asked for.
+	for _, tc := range []struct {
+		deleted  bool
+		expected int
+	}{
+		{deleted: false, expected: len(userIDs)},
+		{deleted: true, expected: len(userIDs) + 1},
+	} {
+		w := request(t, "GET", fmt.Sprintf("%s?limit=10&deleted=%t", rulesPrivateEndpoint, tc.deleted), nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var page ConfigsView
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		assert.Len(t, page.Configs, tc.expected, "deleted=%t", tc.deleted)
+		assert.Nil(t, page.NextCursor)
+	}
+
+	// since and limit compose.
+	w = request(t, "GET", fmt.Sprintf("%s?since=%d&limit=2", rulesPrivateEndpoint, views[2].ID), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var found ConfigsView
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &found))
+	next := views[4].ID
+	assert.Equal(t, ConfigsView{
+		Configs:    map[string]userconfig.View{userIDs[3]: views[3], userIDs[4]: views[4]},
+		NextCursor: &next,
+	}, found)
+
+	// Without a limit, all configs are returned and there's no cursor.
+	w = request(t, "GET", rulesPrivateEndpoint, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "next_cursor")
+	found = ConfigsView{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &found))
+	assert.Len(t, found.Configs, len(userIDs))
+
+	// Without a limit, changes still include deletions, for pollers.
+	w = request(t, "GET", fmt.Sprintf("%s?since=%d", rulesPrivateEndpoint, views[6].ID), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	found = ConfigsView{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &found))
+	assert.Equal(t, map[string]userconfig.View{deletedUserID: all.Configs[deletedUserID]}, found.Configs)
+
+	for _, limit := range []string{"0", "-1", "foo"} {
+		w := request(t, "GET", rulesPrivateEndpoint+"?limit="+limit, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code, limit)
+	}
+}
+
 var amCfgValidationTests = []struct {
 	config      string
 	shouldFail  bool
@@ -306,6 +505,106 @@ func Test_SetConfig_ValidatesAlertmanagerConfig(t *testing.T) {
 	}
 }
 
+var rulesCfgValidationTests = []struct {
+	name        string
+	config      userconfig.RulesConfig
+	shouldFail  bool
+	errContains string
+}{
+	{
+		name: "valid",
+		config: userconfig.RulesConfig{
+			FormatVersion: userconfig.RuleFormatV2,
+			Files: map[string]string{
+				"rules.yml": "groups:\n- name: example\n  rules:\n  - record: job:up:sum\n    expr: sum by (job) (up)\n",
+			},
+		},
+	}, {
+		name: "invalid expression",
+		config: userconfig.RulesConfig{
+			FormatVersion: userconfig.RuleFormatV2,
+			Files: map[string]string{
+				"rules.yml": "groups:\n- name: example\n  rules:\n  - alert: Down\n    expr: up == \n",
+			},
+		},
+		shouldFail:  true,
+		errContains: "could not parse expression",
+	}, {
+		name: "invalid rule",
+		config: userconfig.RulesConfig{
+			FormatVersion: userconfig.RuleFormatV2,
+			Files: map[string]string{
+				"rules.yml": "groups:\n- name: example\n  rules:\n  - expr: up\n",
+			},
+		},
+		shouldFail:  true,
+		errContains: "error parsing rules.yml",
+	}, {
+		name: "2.x rules declared as 1.x",
+		config: userconfig.RulesConfig{
+			FormatVersion: userconfig.RuleFormatV1,
+			Files: map[string]string{
+				"rules.yml": "groups:\n- name: example\n  rules:\n  - record: job:up:sum\n    expr: sum by (job) (up)\n",
+			},
+		},
+		shouldFail:  true,
+		errContains: "rules.yml is in the Prometheus 2.x rule format, but the rule format version is 1",
+	}, {
+		name: "1.x rules declared as 2.x",
+		config: userconfig.RulesConfig{
+			FormatVersion: userconfig.RuleFormatV2,
+			Files: map[string]string{
+				"rules.yml": "job:up:sum = sum(up) by (job)\n",
+			},
+		},
+		shouldFail:  true,
+		errContains: "error parsing rules.yml",
+	},
+}
+
+func Test_ValidateRulesConfig(t *testing.T) {
+	setup(t)
+	defer cleanup(t)
+
+	userID := makeUserID()
+	for _, test := range rulesCfgValidationTests {
+		cfg := userconfig.Config{RulesConfig: test.config}
+		resp := requestAsUser(t, userID, "POST", "/api/prom/configs/rules/validate", "", readerFromConfig(t, cfg))
+		data := map[string]string{}
+		err := json.Unmarshal(resp.Body.Bytes(), &data)
+		assert.NoError(t, err, test.name)
+
+		if !test.shouldFail {
+			assert.Equal(t, map[string]string{"status": "success"}, data, test.name)
+			assert.Equal(t, http.StatusOK, resp.Code, test.name)
+			continue
+		}
+
+		assert.Equal(t, http.StatusBadRequest, resp.Code, test.name)
+		assert.Equal(t, "error", data["status"], test.name)
+		assert.Contains(t, data["error"], test.errContains, test.name)
+	}
+}
+
+func Test_SetConfig_ValidatesRulesConfig(t *testing.T) {
+	setup(t)
+	defer cleanup(t)
+
+	userID := makeUserID()
+	for _, test := range rulesCfgValidationTests {
+		cfg := userconfig.Config{RulesConfig: test.config}
+		resp := requestAsUser(t, userID, "POST", rulesEndpoint, "", readerFromConfig(t, cfg))
+
+		if !test.shouldFail {
+			assert.Equal(t, http.StatusNoContent, resp.Code, test.name)
+			continue
+		}
+
+		assert.Equal(t, http.StatusBadRequest, resp.Code, test.name)
+		assert.Contains(t, resp.Body.String(), test.errContains, test.name)
+	}
+}
+
 func Test_SetConfig_ValidatesAlertmanagerConfig_WithEmailEnabled(t *testing.T) {
 	config := `
         global:
@@ -345,11 +644,138 @@ func Test_SetConfig_BodyFormat(t *testing.T) {
 
 func testSetConfigBodyFormat(bodyFile string, contentType string, t *testing.T) {
 	userID := makeUserID()
-	file, err := os.Open(bodyFile)
+	body, err := os.ReadFile(bodyFile)
 	require.NoError(t, err)
-	defer file.Close()
-	resp := requestAsUser(t, userID, "POST", "/api/prom/configs/alertmanager", contentType, file)
+	resp := requestAsUser(t, userID, "POST", "/api/prom/configs/alertmanager", contentType, bytes.NewReader(body))
 	assert.Equal(t, http.StatusNoContent, resp.Code, "error body: %s Content-Type: %s", resp.Body.String(), contentType)
+
+	// The config reads back as YAML, whatever format it was posted in.
+	var expected userconfig.Config
+	require.NoError(t, yaml.Unmarshal(body, &expected))
+	for _, accept := range []string{"application/yaml", "text/yaml"} {
+		resp = getAsUser(t, userID, "/api/prom/configs/alertmanager", accept)
+		require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+		assert.Equal(t, "application/yaml", resp.Header().Get("Content-Type"))
+		var view userconfig.View
+		require.NoError(t, yaml.Unmarshal(resp.Body.Bytes(), &view), resp.Body.String())
+		assert.Equal(t, expected, view.Config, "Accept: %s", accept)
+	}
+}
+
+func Test_GetConfig_Accept(t *testing.T) {
+	setup(t)
+	defer cleanup(t)
+
+	userID := makeUserID()
+	for _, c := range allClients {
+		view := c.post(t, userID, makeConfig())
+
+		for _, accept := range []string{"", "application/json", "*/*", "text/html, application/*"} {
+			resp := getAsUser(t, userID, c.Endpoint, accept)
+			require.Equal(t, http.StatusOK, resp.Code, "Accept: %s", accept)
+			assert.Equal(t, "application/json", resp.Header().Get("Content-Type"), "Accept: %s", accept)
+			assert.Equal(t, view, parseView(t, resp.Body.Bytes()), "Accept: %s", accept)
+		}
+
+		resp := getAsUser(t, userID, c.Endpoint, "text/html")
+		assert.Equal(t, http.StatusNotAcceptable, resp.Code)
+	}
+}
+
+func TestAcceptedConfigFormat(t *testing.T) {
+	tests := []struct {
+		accept   string
+		expected string
+	}{
+		{"", FormatJSON},
+		{"application/json", FormatJSON},
+		{"application/yaml", FormatYAML},
+		{"text/yaml; charset=utf-8", FormatYAML},
+		{"application/yaml, application/json", FormatYAML},
+		{"text/html, application/yaml", FormatYAML},
+		{"*/*", FormatJSON},
+		{"application/*", FormatJSON},
+		{"text/*", FormatYAML},
+		{"text/html", FormatInvalid},
+		{"text/html, text/plain", FormatInvalid},
+		{"application/json;q=0, application/yaml", FormatYAML},
+		{"application/yaml;q=0", FormatInvalid},
+		{"application/json;q=0.5, application/yaml;q=0.9", FormatYAML},
+		{"application/json;q=0.9, application/yaml;q=0.9", FormatJSON},
+		{"application/json;q=0, */*", FormatYAML},
+		{"*/*;q=0", FormatInvalid},
+		{"application/yaml;q=foo, application/json", FormatJSON},
+	}
+	for _, test := range tests {
+		t.Run(test.accept, func(t *testing.T) {
+			assert.Equal(t, test.expected, acceptedConfigFormat(test.accept))
+		})
+	}
+}
+
+func Test_SetConfig_RejectsDuplicateKeys(t *testing.T) {
+	setup(t)
+	defer cleanup(t)
+
+	for _, tc := range []struct {
+		name        string
+		contentType string
+		body        string
+		keyPath     string
+	}{
+		{
+			name:        "json top-level key",
+			contentType: "application/json",
+			body:        `{"rule_format_version": "2", "alertmanager_config": "route: {}", "alertmanager_config": ""}`,
+			keyPath:     "alertmanager_config",
+		},
+		{
+			name:        "json top-level key differing in case",
+			contentType: "application/json",
+			body:        `{"rule_format_version": "2", "alertmanager_config": "route: {}", "Alertmanager_Config": ""}`,
+			keyPath:     "Alertmanager_Config",
+		},
+		{
+			name:        "json nested key",
+			contentType: "application/json",
+			body:        `{"rule_format_version": "2", "rules_files": {"a.yml": "groups: []", "a.yml": ""}}`,
+			keyPath:     "rules_files.a.yml",
+		},
+		{
+			name:        "yaml top-level key",
+			contentType: "application/yaml",
+			body:        "rule_format_version: '2'\nalertmanager_config: 'route: {}'\nalertmanager_config: ''\n",
+			keyPath:     "alertmanager_config",
+		},
+		{
+			name:        "yaml nested key",
+			contentType: "application/yaml",
+			body:        "rule_format_version: '2'\ntemplate_files:\n  a.tmpl: ''\n  a.tmpl: ''\n",
+			keyPath:     "template_files.a.tmpl",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			userID := makeUserID()
+			app.AllowDuplicateKeys = false
+			resp := requestAsUser(t, userID, "POST", "/api/prom/configs/rules", tc.contentType, strings.NewReader(tc.body))
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			assert.Contains(t, resp.Body.String(), fmt.Sprintf("duplicate key %q", tc.keyPath))
+
+			resp = requestAsUser(t, userID, "GET", "/api/prom/configs/rules", "", nil)
+			assert.Equal(t, http.StatusNotFound, resp.Code)
+
+			// Legacy clients may opt out, in which case the last value wins.
+			app.AllowDuplicateKeys = true
+			defer func() { app.AllowDuplicateKeys = false }()
+			resp = requestAsUser(t, userID, "POST", "/api/prom/configs/rules", tc.contentType, strings.NewReader(tc.body))
+			assert.Equal(t, http.StatusNoContent, resp.Code, resp.Body.String())
+		})
+	}
+
+	// Nested objects are maps, so their keys may differ only by case.
+	body := `{"rule_format_version": "2", "template_files": {"a.tmpl": "", "A.tmpl": ""}}`
+	resp := requestAsUser(t, makeUserID(), "POST", "/api/prom/configs/rules", "application/json", strings.NewReader(body))
+	assert.Equal(t, http.StatusNoContent, resp.Code, resp.Body.String())
 }
 
 func TestParseConfigFormat(t *testing.T) {
@@ -396,3 +822,1186 @@ func Test_SetConfig_ValidateTemplateFiles(t *testing.T) {
 	err := validateTemplateFiles(cfg)
 	assert.Equal(t, nil, err)
 }
+
+func Test_SetConfig_ValidatesRuleGroupNames(t *testing.T) {
+	setupWithConfig(t, Config{
+		Validation: ValidationConfig{
+			RuleGroupNamePattern: "team-.*",
+		},
+	})
+	defer cleanup(t)
+
+	userID := makeUserID()
+	file, err := os.Open("testdata/config_invalid_group_name.yml")
+	require.NoError(t, err)
+	defer file.Close()
+	resp := requestAsUser(t, userID, "POST", rulesEndpoint, "text/yaml", file)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"container_spec_memory_limit_bytes:container" (rule1.yml)`)
+	assert.NotContains(t, resp.Body.String(), `"team-storage"`)
+
+	// The same config is accepted when no pattern is configured.
+	cleanup(t)
+	setup(t)
+	file, err = os.Open("testdata/config_invalid_group_name.yml")
+	require.NoError(t, err)
+	defer file.Close()
+	resp = requestAsUser(t, userID, "POST", rulesEndpoint, "text/yaml", file)
+	assert.Equal(t, http.StatusNoContent, resp.Code, resp.Body.String())
+}
+
+func Test_ValidateRulesConfig_RuleGroupNames(t *testing.T) {
+	exemptUserID := "exempt"
+	setupWithConfig(t, Config{
+		Validation: ValidationConfig{
+			RuleGroupNamePattern: "team-.*",
+			TenantExemptions: map[string][]string{
+				exemptUserID: {checkRuleGroupNames},
+			},
+		},
+	})
+	defer cleanup(t)
+
+	body, err := os.ReadFile("testdata/config_invalid_group_name.yml")
+	require.NoError(t, err)
+
+	resp := requestAsUser(t, makeUserID(), "POST", "/api/prom/configs/rules/validate", "text/yaml", bytes.NewReader(body))
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	data := map[string]string{}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &data))
+	assert.Equal(t, "error", data["status"])
+	assert.Contains(t, data["error"], `"container_spec_memory_limit_bytes:container" (rule1.yml)`)
+
+	resp = requestAsUser(t, exemptUserID, "POST", "/api/prom/configs/rules/validate", "text/yaml", bytes.NewReader(body))
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+}
+
+func Test_SetConfig_ValidatesRuleExpressions(t *testing.T) {
+	body, err := os.ReadFile("testdata/config_complex_expression.yml")
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		maxLength, maxNodes int
+		errContains         string
+	}{
+		"disabled": {},
+		"max length": {
+			maxLength:   100,
+			errContains: `rule "AnyServiceDown" in group "generated" (rules.yml): expression length`,
+		},
+		"max nodes": {
+			maxNodes:    50,
+			errContains: `rule "AnyServiceDown" in group "generated" (rules.yml): expression has`,
+		},
+		"generous limits": {
+			maxLength: 1000,
+			maxNodes:  1000,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			setupWithConfig(t, Config{
+				Validation: ValidationConfig{
+					MaxRuleExpressionLength: tc.maxLength,
+					MaxRuleExpressionNodes:  tc.maxNodes,
+				},
+			})
+			defer cleanup(t)
+
+			resp := requestAsUser(t, makeUserID(), "POST", rulesEndpoint, "text/yaml", bytes.NewReader(body))
+			if tc.errContains == "" {
+				assert.Equal(t, http.StatusNoContent, resp.Code, resp.Body.String())
+				return
+			}
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			assert.Contains(t, resp.Body.String(), tc.errContains)
+			assert.NotContains(t, resp.Body.String(), "job:up:sum")
+		})
+	}
+}
+
+func Test_New_InvalidRuleGroupNamePattern(t *testing.T) {
+	_, err := New(nil, Config{Validation: ValidationConfig{RuleGroupNamePattern: "team-("}})
+	assert.Error(t, err)
+}
+
+func Test_GetTemplateFunctionUsage(t *testing.T) {
+	setup(t)
+	defer cleanup(t)
+
+	userID1 := makeUserID()
+	userID2 := makeUserID()
+	userID3 := makeUserID()
+
+	cfg1 := makeConfig()
+	cfg1.TemplateFiles = map[string]string{
+		"a.tmpl": `{{ define "a" }}{{ .Value | toUpper }}{{ if .Values }}{{ .Values | join " " }}{{ end }}{{ end }}`,
+	}
+	cfg2 := makeConfig()
+	cfg2.TemplateFiles = map[string]string{
+		"b.tmpl": `{{ define "b" }}{{ range .Alerts }}{{ title .Status }}{{ end }}{{ toUpper "x" }}{{ end }}`,
+	}
+	// Templates written inline in the Alertmanager config count too.
+	cfg3 := makeConfig()
+	cfg3.AlertmanagerConfig = `
+route:
+  receiver: noop
+receivers:
+- name: noop
+  slack_configs:
+  - api_url: http://slack
+    text: '{{ .Status | toLower }}'
+`
+	alertManagerConfigClient.post(t, userID1, cfg1)
+	alertManagerConfigClient.post(t, userID2, cfg2)
+	alertManagerConfigClient.post(t, userID3, cfg3)
+
+	w := request(t, "GET", "/private/api/prom/configs/alertmanager/template-function-usage", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var found TemplateFunctionUsageView
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &found))
+	assert.Equal(t, TemplateFunctionUsageView{Functions: map[string][]string{
+		"toUpper": {userID1, userID2},
+		"toLower": {userID3},
+		"join":    {userID1},
+		"title":   {userID2},
+	}}, found)
+}
+
+func Test_GetRulesChurn(t *testing.T) {
+	setup(t)
+	defer cleanup(t)
+
+	userID1 := makeUserID()
+	userID2 := makeUserID()
+	rulesClient.post(t, userID1, makeConfig())
+	rulesClient.post(t, userID1, makeConfig())
+	rulesClient.post(t, userID1, makeConfig())
+	rulesClient.post(t, userID2, makeConfig())
+	w := requestAsUser(t, userID2, "DELETE", "/api/prom/configs/deactivate", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	for _, tc := range []struct {
+		query  string
+		window string
+	}{
+		{"", "1d"},
+		{"?window=1h", "1h"},
+	} {
+		w := request(t, "GET", "/private/api/prom/configs/rules/churn"+tc.query, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var found RulesChurnView
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &found))
+		assert.Equal(t, RulesChurnView{
+			Window:   tc.window,
+			Versions: map[string]int{userID1: 3, userID2: 2},
+		}, found)
+	}
+
+	for _, window := range []string{"foo", "0s", "-1h"} {
+		w := request(t, "GET", "/private/api/prom/configs/rules/churn?window="+window, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code, window)
+	}
+}
+
+func Test_RulesEvaluationStatus(t *testing.T) {
+	setup(t)
+	defer cleanup(t)
+
+	userID := makeUserID()
+	w := requestAsUser(t, userID, "GET", "/api/prom/configs/rules/evaluation-status", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"groups": []}`, w.Body.String())
+
+	cfg := makeConfig()
+	cfg.RulesConfig.Files = map[string]string{"rules.yaml": `groups:
+- name: example
+  rules:
+  - record: up:sum
+    expr: sum(up)
+  - alert: HighErrorRate
+    expr: rate(errors_total[5m]) > 1
+- name: other
+  rules:
+  - record: up:count
+    expr: count(up)
+`}
+	rulesClient.post(t, userID, cfg)
+
+	postStatus := func(status userconfig.RulesEvaluationStatus) {
+		body, err := json.Marshal(status)
+		require.NoError(t, err)
+		w := requestAsUser(t, userID, "POST", "/private/api/prom/configs/rules/evaluation-status", "application/json", bytes.NewReader(body))
+		require.Equal(t, http.StatusNoContent, w.Code)
+	}
+	getStatus := func() userconfig.RulesEvaluationStatus {
+		w := requestAsUser(t, userID, "GET", "/api/prom/configs/rules/evaluation-status", "", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var found userconfig.RulesEvaluationStatus
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &found))
+		return found
+	}
+
+	evaluatedAt := time.Unix(1600000000, 0).UTC()
+	example := userconfig.RuleGroupEvaluationStatus{
+		Name:           "example",
+		File:           "rules.yaml",
+		LastEvaluation: evaluatedAt,
+		Rules: []userconfig.RuleEvaluationStatus{
+			{Name: "up:sum", Health: "ok", LastEvaluation: evaluatedAt},
+			{Name: "HighErrorRate", Health: "err", LastError: "many-to-many matching not allowed", LastEvaluation: evaluatedAt},
+		},
+	}
+	other := userconfig.RuleGroupEvaluationStatus{
+		Name:           "other",
+		File:           "rules.yaml",
+		LastEvaluation: evaluatedAt,
+		Rules:          []userconfig.RuleEvaluationStatus{{Name: "up:count", Health: "ok", LastEvaluation: evaluatedAt}},
+	}
+
+	// The groups of a user can be evaluated, and reported, by different rulers.
+	postStatus(userconfig.RulesEvaluationStatus{Groups: []userconfig.RuleGroupEvaluationStatus{other}})
+	postStatus(userconfig.RulesEvaluationStatus{Groups: []userconfig.RuleGroupEvaluationStatus{example}})
+	assert.Equal(t, userconfig.RulesEvaluationStatus{Groups: []userconfig.RuleGroupEvaluationStatus{example, other}}, getStatus())
+
+	// Reporting a group again only replaces its own status.
+	other.Rules[0].Health = "err"
+	other.Rules[0].LastError = "query timed out"
+	postStatus(userconfig.RulesEvaluationStatus{Groups: []userconfig.RuleGroupEvaluationStatus{other}})
+	assert.Equal(t, userconfig.RulesEvaluationStatus{Groups: []userconfig.RuleGroupEvaluationStatus{example, other}}, getStatus())
+
+	// Groups missing from the rules are left out.
+	removed := other
+	removed.Name = "removed"
+	postStatus(userconfig.RulesEvaluationStatus{Groups: []userconfig.RuleGroupEvaluationStatus{removed}})
+	assert.Equal(t, userconfig.RulesEvaluationStatus{Groups: []userconfig.RuleGroupEvaluationStatus{example, other}}, getStatus())
+
+	// Other users only see their own status.
+	w = requestAsUser(t, makeUserID(), "GET", "/api/prom/configs/rules/evaluation-status", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"groups": []}`, w.Body.String())
+
+	w = requestAsUser(t, userID, "POST", "/private/api/prom/configs/rules/evaluation-status", "application/json", strings.NewReader("{"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func Test_GetEffectiveConfig_ResolvesRuleGroupRefs(t *testing.T) {
+	setup(t)
+	defer cleanup(t)
+
+	shared := "groups:\n- name: shared\n  rules:\n  - record: up:sum\n    expr: sum(up)\n"
+	main := "groups:\n- $ref: shared.yml#shared\n"
+	cfg := userconfig.Config{
+		RulesConfig: userconfig.RulesConfig{
+			FormatVersion: userconfig.RuleFormatV2,
+			Files: map[string]string{
+				"shared.yml": shared,
+				"main.yml":   main,
+			},
+		},
+	}
+
+	userID := makeUserID()
+	view := rulesClient.post(t, userID, cfg)
+	assert.Equal(t, cfg, view.Config, "the stored config must be kept verbatim")
+
+	w := requestAsUser(t, userID, "GET", "/api/prom/configs/rules/effective", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	effective := parseView(t, w.Body.Bytes())
+	assert.Equal(t, view.ID, effective.ID)
+	assert.Equal(t, shared, effective.Config.RulesConfig.Files["shared.yml"])
+	assert.Equal(t, "groups:\n  - name: shared\n    rules:\n      - record: up:sum\n        expr: sum(up)\n", effective.Config.RulesConfig.Files["main.yml"])
+}
+
+func Test_GetEffectiveConfig_NotFound(t *testing.T) {
+	setup(t)
+	defer cleanup(t)
+
+	w := requestAsUser(t, makeUserID(), "GET", "/api/prom/configs/rules/effective", "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func Test_SetConfig_RejectsInvalidRuleGroupRefs(t *testing.T) {
+	setup(t)
+	defer cleanup(t)
+
+	for name, tc := range map[string]struct {
+		files       map[string]string
+		errContains string
+	}{
+		"unresolved": {
+			files:       map[string]string{"main.yml": "groups:\n- $ref: missing.yml\n"},
+			errContains: "no such rules file",
+		},
+		"cyclic": {
+			files: map[string]string{
+				"a.yml": "groups:\n- $ref: b.yml\n",
+				"b.yml": "groups:\n- $ref: a.yml\n",
+			},
+			errContains: "cyclic $ref",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := userconfig.Config{
+				RulesConfig: userconfig.RulesConfig{FormatVersion: userconfig.RuleFormatV2, Files: tc.files},
+			}
+			w := requestAsUser(t, makeUserID(), "POST", rulesEndpoint, "", readerFromConfig(t, cfg))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.errContains)
+		})
+	}
+}
+
+func Test_ValidateAlertmanagerConfig_MaxReloadCost(t *testing.T) {
+	expensive, err := os.ReadFile("testdata/alertmanager_expensive.yml")
+	require.NoError(t, err)
+
+	// 7 routes, 4 receivers with 2 integrations each, 2 inhibit rules and 2 template globs.
+	const expectedCost = 7*reloadCostPerRoute + 4*(reloadCostPerReceiver+2*reloadCostPerIntegration) + 2*reloadCostPerInhibitRule + 2*reloadCostPerTemplate
+	amCfg, err := amconfig.Load(string(expensive))
+	require.NoError(t, err)
+	assert.Equal(t, expectedCost, alertmanagerReloadCost(amCfg, 0))
+	assert.Equal(t, expectedCost+reloadCostPerTemplate, alertmanagerReloadCost(amCfg, 1))
+
+	for name, tc := range map[string]struct {
+		maxCost    int
+		shouldFail bool
+	}{
+		"disabled":         {maxCost: 0},
+		"within the limit": {maxCost: expectedCost},
+		"over the limit":   {maxCost: expectedCost - 1, shouldFail: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			setupWithConfig(t, Config{Validation: ValidationConfig{MaxAlertmanagerReloadCost: tc.maxCost}})
+			defer cleanup(t)
+
+			userID := makeUserID()
+			resp := requestAsUser(t, userID, "POST", "/api/prom/configs/alertmanager/validate", "", bytes.NewReader(expensive))
+			data := map[string]string{}
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &data))
+
+			cfg := userconfig.Config{AlertmanagerConfig: string(expensive), RulesConfig: userconfig.RulesConfig{FormatVersion: userconfig.RuleFormatV2}}
+			setResp := requestAsUser(t, userID, "POST", alertManagerConfigEndpoint, "", readerFromConfig(t, cfg))
+
+			if !tc.shouldFail {
+				assert.Equal(t, http.StatusOK, resp.Code)
+				assert.Equal(t, "success", data["status"])
+				assert.Equal(t, http.StatusNoContent, setResp.Code, setResp.Body.String())
+				return
+			}
+			expectedErr := fmt.Sprintf("estimated Alertmanager reload cost %d exceeds the maximum of %d", expectedCost, tc.maxCost)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			assert.Equal(t, "error", data["status"])
+			assert.Equal(t, expectedErr, data["error"])
+			assert.Equal(t, http.StatusBadRequest, setResp.Code)
+			assert.Contains(t, setResp.Body.String(), expectedErr)
+		})
+	}
+}
+
+func Test_ValidateAlertmanagerConfig_ExternalLabelsWarnings(t *testing.T) {
+	setup(t)
+	defer cleanup(t)
+	app.ExternalLabels = labels.FromStrings("cluster", "prod")
+
+	body, err := os.ReadFile("testdata/config_external_labels_mismatch.yml")
+	require.NoError(t, err)
+	var cfg userconfig.Config
+	require.NoError(t, yaml.Unmarshal(body, &cfg))
+
+	userID := makeUserID()
+
+	// Without stored rules, only the external label values are checked.
+	resp := requestAsUser(t, userID, "POST", "/api/prom/configs/alertmanager/validate", "", strings.NewReader(cfg.AlertmanagerConfig))
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.JSONEq(t, `{
+		"status": "success",
+		"warnings": [
+			"route.routes[1] matches external label cluster=\"staging\", which never matches the external label value \"prod\""
+		]
+	}`, resp.Body.String())
+
+	// Warnings never prevent a config from being stored.
+	resp = requestAsUser(t, userID, "POST", rulesEndpoint, "text/yaml", bytes.NewReader(body))
+	require.Equal(t, http.StatusNoContent, resp.Code, resp.Body.String())
+
+	resp = requestAsUser(t, userID, "POST", "/api/prom/configs/alertmanager/validate", "", strings.NewReader(cfg.AlertmanagerConfig))
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.JSONEq(t, `{
+		"status": "success",
+		"warnings": [
+			"route.routes[0] matches external labels and label \"team\", but no alerting rule sets \"team\"",
+			"route.routes[1] matches external label cluster=\"staging\", which never matches the external label value \"prod\""
+		]
+	}`, resp.Body.String())
+}
+
+func Test_ValidateAlertmanagerConfig_IneffectiveContinueWarnings(t *testing.T) {
+	setup(t)
+	defer cleanup(t)
+
+	body, err := os.ReadFile("testdata/alertmanager_ineffective_continue.yml")
+	require.NoError(t, err)
+
+	resp := requestAsUser(t, makeUserID(), "POST", "/api/prom/configs/alertmanager/validate", "", bytes.NewReader(body))
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.JSONEq(t, `{
+		"status": "success",
+		"warnings": [
+			"route.routes[1].routes[1] sets continue: true, but has neither child routes nor sibling routes after it to continue to",
+			"route.routes[2].routes[0] sets continue: true, but has neither child routes nor sibling routes after it to continue to"
+		]
+	}`, resp.Body.String())
+}
+
+func Test_CompareConfigStructure(t *testing.T) {
+	setup(t)
+	defer cleanup(t)
+
+	golden := makeUserID()
+	goldenCfg := makeConfig()
+	goldenCfg.RulesConfig.Files = map[string]string{
+		"rules.yml": "groups:\n- name: a\n  rules:\n  - record: a\n    expr: up\n- name: b\n  rules: []\n",
+	}
+	goldenCfg.AlertmanagerConfig = "route:\n  receiver: default\nreceivers:\n- name: default\n- name: team\n"
+	rulesClient.post(t, golden, goldenCfg)
+
+	// Values differ, but the structure is the same.
+	same := goldenCfg
+	same.RulesConfig.Files = map[string]string{
+		"rules.yml": "groups:\n- name: a\n  interval: 1m\n  rules:\n  - record: a\n    expr: sum(up)\n- name: b\n  rules: []\n",
+	}
+	// Structure differs.
+	different := makeConfig()
+	different.RulesConfig.Files = map[string]string{
+		"rules.yml": "groups:\n- name: a\n  rules: []\n- name: c\n  rules: []\n",
+	}
+	different.AlertmanagerConfig = "route:\n  receiver: default\nreceivers:\n- name: default\n- name: other\n"
+
+	for name, tc := range map[string]struct {
+		userID, path string
+		cfg          userconfig.Config
+		code         int
+		expected     ConfigStructureView
+	}{
+		"same tenant, same structure": {
+			userID:   golden,
+			path:     "/api/prom/configs/rules/compare-structure",
+			cfg:      same,
+			code:     http.StatusOK,
+			expected: ConfigStructureView{Matches: true, RuleGroups: StructureDiff{Missing: []string{}, Extra: []string{}}, Receivers: StructureDiff{Missing: []string{}, Extra: []string{}}},
+		},
+		"same tenant, explicit against": {
+			userID: golden,
+			path:   "/api/prom/configs/rules/compare-structure?against=" + golden,
+			cfg:    different,
+			code:   http.StatusOK,
+			expected: ConfigStructureView{
+				RuleGroups: StructureDiff{Missing: []string{"b (rules.yml)"}, Extra: []string{"c (rules.yml)"}},
+				Receivers:  StructureDiff{Missing: []string{"team"}, Extra: []string{"other"}},
+			},
+		},
+		"other tenant requires admin": {
+			userID: makeUserID(),
+			path:   "/api/prom/configs/rules/compare-structure?against=" + golden,
+			cfg:    same,
+			code:   http.StatusForbidden,
+		},
+		"other tenant through private endpoint": {
+			path: "/private/api/prom/configs/rules/compare-structure?against=" + golden,
+			cfg:  different,
+			code: http.StatusOK,
+			expected: ConfigStructureView{
+				RuleGroups: StructureDiff{Missing: []string{"b (rules.yml)"}, Extra: []string{"c (rules.yml)"}},
+				Receivers:  StructureDiff{Missing: []string{"team"}, Extra: []string{"other"}},
+			},
+		},
+		"private endpoint requires against": {
+			path: "/private/api/prom/configs/rules/compare-structure",
+			cfg:  same,
+			code: http.StatusBadRequest,
+		},
+		"unknown tenant": {
+			path: "/private/api/prom/configs/rules/compare-structure?against=" + makeUserID(),
+			cfg:  same,
+			code: http.StatusNotFound,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var w *httptest.ResponseRecorder
+			if tc.userID == "" {
+				w = request(t, "POST", tc.path, readerFromConfig(t, tc.cfg))
+			} else {
+				w = requestAsUser(t, tc.userID, "POST", tc.path, "", readerFromConfig(t, tc.cfg))
+			}
+			require.Equal(t, tc.code, w.Code, w.Body.String())
+			if tc.code != http.StatusOK {
+				return
+			}
+			var found ConfigStructureView
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &found))
+			assert.Equal(t, tc.expected, found)
+		})
+	}
+}
+
+func Test_ScanConfigs(t *testing.T) {
+	setupWithConfig(t, Config{Scan: ScanConfig{MaxPageSize: 3}})
+	defer cleanup(t)
+
+	expected := map[string]struct{}{}
+	for i := 0; i < 7; i++ {
+		userID := makeUserID()
+		rulesClient.post(t, userID, makeConfig())
+		expected[userID] = struct{}{}
+	}
+
+	for _, limit := range []string{"", "2", "100"} {
+		t.Run("limit="+limit, func(t *testing.T) {
+			found := map[string]struct{}{}
+			cursor := ""
+			for pages := 0; ; pages++ {
+				require.Less(t, pages, 10, "scan doesn't terminate")
+				w := request(t, "GET", "/private/api/prom/configs/rules/scan?limit="+limit+"&cursor="+cursor, nil)
+				require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+				var page ConfigsView
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+				maxPageSize := 3
+				if limit == "2" {
+					maxPageSize = 2
+				}
+				assert.LessOrEqual(t, len(page.Configs), maxPageSize)
+				for userID := range page.Configs {
+					assert.NotContains(t, found, userID)
+					found[userID] = struct{}{}
+				}
+				if page.NextCursor == nil {
+					break
+				}
+				cursor = strconv.FormatUint(uint64(*page.NextCursor), 10)
+			}
+			assert.Equal(t, expected, found)
+		})
+	}
+
+	for _, query := range []string{"limit=0", "limit=-1", "limit=foo", "cursor=foo"} {
+		w := request(t, "GET", "/private/api/prom/configs/rules/scan?"+query, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func Test_ScanConfigs_Throttling(t *testing.T) {
+	setupWithConfig(t, Config{Scan: ScanConfig{MaxPageSize: 10, RateLimit: 0.01}})
+	defer cleanup(t)
+
+	rulesClient.post(t, makeUserID(), makeConfig())
+
+	w := request(t, "GET", "/private/api/prom/configs/rules/scan", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = request(t, "GET", "/private/api/prom/configs/rules/scan", nil)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	require.NoError(t, err)
+	assert.InDelta(t, 100, retryAfter, 1)
+}
+
+func Test_ScanConfigs_YieldsToForeground(t *testing.T) {
+	const maxWait = 100 * time.Millisecond
+	setupWithConfig(t, Config{Scan: ScanConfig{MaxPageSize: 10, MaxForegroundWait: maxWait}})
+	defer cleanup(t)
+
+	rulesClient.post(t, makeUserID(), makeConfig())
+
+	app.foregroundRequests.Inc()
+	defer app.foregroundRequests.Dec()
+
+	// Low priority pages wait for live traffic, but no longer than the max
+	// foreground wait.
+	for _, query := range []string{"", "?priority=low"} {
+		start := time.Now()
+		w := request(t, "GET", "/private/api/prom/configs/rules/scan"+query, nil)
+		assert.Equal(t, http.StatusOK, w.Code, query)
+		assert.GreaterOrEqual(t, time.Since(start), maxWait, query)
+	}
+
+	// Normal priority pages don't wait.
+	start := time.Now()
+	w := request(t, "GET", "/private/api/prom/configs/rules/scan?priority=normal", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Less(t, time.Since(start), maxWait)
+
+	w = request(t, "GET", "/private/api/prom/configs/rules/scan?priority=high", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func Test_ScanConfigs_ServedOnceForegroundCompletes(t *testing.T) {
+	setupWithConfig(t, Config{Scan: ScanConfig{MaxPageSize: 10, MaxForegroundWait: time.Minute}})
+	defer cleanup(t)
+
+	rulesClient.post(t, makeUserID(), makeConfig())
+
+	app.foregroundRequests.Inc()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		app.foregroundRequests.Dec()
+	}()
+	start := time.Now()
+	w := request(t, "GET", "/private/api/prom/configs/rules/scan", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Less(t, time.Since(start), 10*time.Second)
+}
+
+func Test_ScanConfigs_DefaultMaxPageSize(t *testing.T) {
+	// The max page size isn't set when the API is built without flags.
+	setup(t)
+	defer cleanup(t)
+
+	userID := makeUserID()
+	rulesClient.post(t, userID, makeConfig())
+
+	w := request(t, "GET", "/private/api/prom/configs/rules/scan", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var page ConfigsView
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Contains(t, page.Configs, userID)
+	assert.Nil(t, page.NextCursor)
+}
+
+func Test_GetConfig_IncludeValidation(t *testing.T) {
+	setup(t)
+	defer cleanup(t)
+
+	// The validation status is computed and stored when the config is posted.
+	userID := makeUserID()
+	cfg := makeConfig()
+	cfg.AlertmanagerConfig = "route:\n  receiver: noop\n  routes:\n  - receiver: noop\n    continue: true\nreceivers:\n- name: noop\n"
+	view := rulesClient.post(t, userID, cfg)
+	expected := userconfig.ValidationStatus{
+		Valid:    true,
+		Warnings: []string{"route.routes[0] sets continue: true, but has neither child routes nor sibling routes after it to continue to"},
+	}
+	stored, err := database.GetConfigValidationStatus(context.Background(), view.ID)
+	require.NoError(t, err)
+	assert.Equal(t, expected, stored)
+
+	w := requestAsUser(t, userID, "GET", rulesEndpoint+"?include_validation=true", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var found ValidatedView
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &found))
+	assert.Equal(t, ValidatedView{View: view, Validation: expected}, found)
+
+	// The validation status is only included on request.
+	w = requestAsUser(t, userID, "GET", rulesEndpoint, "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), `"validation"`)
+
+	// Configs stored without going through the API are validated when read.
+	invalid := makeConfig()
+	invalid.RulesConfig.Files = map[string]string{"rules.yml": "groups:\n- name: a\n  rules:\n  - record: a\n    expr: sum(\n"}
+	require.NoError(t, database.SetConfig(context.Background(), userID, invalid))
+	w = requestAsUser(t, userID, "GET", rulesEndpoint+"?include_validation=true", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	found = ValidatedView{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &found))
+	assert.Greater(t, found.ID, view.ID)
+	assert.False(t, found.Validation.Valid)
+	assert.Contains(t, found.Validation.Error, "Invalid rules:")
+	_, err = database.GetConfigValidationStatus(context.Background(), found.ID)
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func Test_MergeAlertmanagerConfigs(t *testing.T) {
+	setup(t)
+	defer cleanup(t)
+
+	userA := makeUserID()
+	userB := makeUserID()
+	for userID, fixture := range map[string]string{userA: "testdata/alertmanager_merge_a.yml", userB: "testdata/alertmanager_merge_b.yml"} {
+		amCfg, err := os.ReadFile(fixture)
+		require.NoError(t, err)
+		cfg := makeConfig()
+		cfg.AlertmanagerConfig = string(amCfg)
+		cfg.TemplateFiles = map[string]string{
+			"shared.tmpl":    `{{ define "shared" }}{{ end }}`,
+			userID + ".tmpl": fmt.Sprintf(`{{ define %q }}{{ end }}`, userID),
+			"common.tmpl":    fmt.Sprintf(`{{ define "common" }}%s{{ end }}`, userID),
+		}
+		alertManagerConfigClient.post(t, userID, cfg)
+	}
+
+	w := request(t, "POST", fmt.Sprintf("/private/api/prom/configs/alertmanager/merge?a=%s&b=%s", userA, userB), nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var found AlertmanagerMergeView
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &found))
+
+	assert.Equal(t, []string{
+		fmt.Sprintf("receivers entry \"shared\" is defined differently by %s and %s, the one of %s is kept", userA, userB, userA),
+		fmt.Sprintf("route.receiver of %s differs from the one of %s, which is kept", userB, userA),
+		fmt.Sprintf("route.routes[0] of %s overlaps route.routes[0] of %s, which it shadows", userA, userB),
+		fmt.Sprintf("route.routes[1] of %s overlaps route.routes[1] of %s, which it shadows", userA, userB),
+		fmt.Sprintf("template file \"common.tmpl\" is defined differently by %s and %s, the one of %s is kept", userA, userB, userA),
+	}, found.Conflicts)
+	assert.Equal(t, map[string]string{
+		"shared.tmpl":   `{{ define "shared" }}{{ end }}`,
+		userA + ".tmpl": fmt.Sprintf(`{{ define %q }}{{ end }}`, userA),
+		userB + ".tmpl": fmt.Sprintf(`{{ define %q }}{{ end }}`, userB),
+		"common.tmpl":   fmt.Sprintf(`{{ define "common" }}%s{{ end }}`, userA),
+	}, found.TemplateFiles)
+	assert.Equal(t, `global:
+  slack_api_url: http://slack-a
+route:
+  receiver: team-a
+  group_by:
+  - alertname
+  routes:
+  - receiver: team-a-pager
+    matchers:
+    - severity="critical"
+  - receiver: shared
+    matchers:
+    - team="infra"
+  - receiver: team-b-pager
+    matchers:
+    - severity="critical"
+    - service="b"
+  - receiver: shared
+    matchers:
+    - team="infra"
+    - env="prod"
+    continue: true
+  - receiver: team-b
+    matchers:
+    - service="b"
+inhibit_rules:
+- source_matchers:
+  - severity="critical"
+  target_matchers:
+  - severity="warning"
+  equal:
+  - alertname
+receivers:
+- name: team-a
+- name: team-a-pager
+  slack_configs:
+  - channel: '#a-pager'
+- name: shared
+  slack_configs:
+  - channel: '#infra'
+- name: team-b
+  slack_configs:
+  - api_url: http://slack-b
+    channel: '#b'
+- name: team-b-pager
+templates:
+- '*.tmpl'
+- b/*.tmpl
+`, found.AlertmanagerConfig)
+
+	// Merging is deterministic and doesn't store anything.
+	w2 := request(t, "POST", fmt.Sprintf("/private/api/prom/configs/alertmanager/merge?a=%s&b=%s", userA, userB), nil)
+	assert.Equal(t, w.Body.String(), w2.Body.String())
+	for userID, fixture := range map[string]string{userA: "testdata/alertmanager_merge_a.yml", userB: "testdata/alertmanager_merge_b.yml"} {
+		amCfg, err := os.ReadFile(fixture)
+		require.NoError(t, err)
+		assert.Equal(t, string(amCfg), alertManagerConfigClient.get(t, userID).Config.AlertmanagerConfig)
+	}
+
+	for _, query := range []string{"", "?a=" + userA, "?a=" + userA + "&b=" + userA} {
+		w := request(t, "POST", "/private/api/prom/configs/alertmanager/merge"+query, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+	w = request(t, "POST", fmt.Sprintf("/private/api/prom/configs/alertmanager/merge?a=%s&b=%s", userA, makeUserID()), nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func Test_SetConfig_StrictValidation(t *testing.T) {
+	strict, err := os.ReadFile("testdata/config_strict_marker.yml")
+	require.NoError(t, err)
+	lenient := bytes.Replace(strict, []byte("  # cortex:strict\n"), nil, 1)
+	require.NotEqual(t, strict, lenient)
+	const strictErr = "Invalid Alertmanager config: warnings are errors with strict validation: route.routes[0] sets continue: true"
+
+	for name, tc := range map[string]struct {
+		strict     bool
+		body       []byte
+		shouldFail bool
+	}{
+		"marker":                       {body: strict, shouldFail: true},
+		"no marker":                    {body: lenient},
+		"global strictness":            {strict: true, body: lenient, shouldFail: true},
+		"global strictness and marker": {strict: true, body: strict, shouldFail: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			setupWithConfig(t, Config{Validation: ValidationConfig{Strict: tc.strict}})
+			defer cleanup(t)
+
+			var cfg userconfig.Config
+			require.NoError(t, yaml.Unmarshal(tc.body, &cfg))
+
+			resp := requestAsUser(t, makeUserID(), "POST", rulesEndpoint, "text/yaml", bytes.NewReader(tc.body))
+			validateResp := requestAsUser(t, makeUserID(), "POST", "/api/prom/configs/alertmanager/validate", "", strings.NewReader(cfg.AlertmanagerConfig))
+			if !tc.shouldFail {
+				assert.Equal(t, http.StatusNoContent, resp.Code, resp.Body.String())
+				assert.Equal(t, http.StatusOK, validateResp.Code, validateResp.Body.String())
+				assert.Contains(t, validateResp.Body.String(), `"warnings"`)
+				return
+			}
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			assert.Contains(t, resp.Body.String(), strictErr)
+			assert.Equal(t, http.StatusBadRequest, validateResp.Code)
+			assert.Contains(t, validateResp.Body.String(), `"status":"error"`)
+		})
+	}
+}
+
+func Test_SetConfig_AllErrors(t *testing.T) {
+	cfg := makeConfig()
+	cfg.RulesConfig.Files = map[string]string{
+		"a.yml": "groups:\n- name: a\n  rules:\n  - record: a\n    expr: sum(\n  - record: b\n    expr: rate(\n",
+		"b.yml": "groups:\n- name: b\n  rules:\n  - alert: B\n    expr: up ==\n",
+	}
+	cfg.TemplateFiles = map[string]string{
+		"a.tmpl": `{{ define "a" }}{{ end`,
+		"b.tmpl": `{{ define "b" }}{{ .Foo }`,
+	}
+
+	for name, tc := range map[string]struct {
+		maxReportedErrors int
+		expectedErrors    int
+	}{
+		"first problems only": {maxReportedErrors: 3, expectedErrors: 3},
+		"all problems":        {maxReportedErrors: 0, expectedErrors: 5},
+	} {
+		t.Run(name, func(t *testing.T) {
+			setupWithConfig(t, Config{Validation: ValidationConfig{MaxReportedErrors: tc.maxReportedErrors}})
+			defer cleanup(t)
+			userID := makeUserID()
+
+			resp := requestAsUser(t, userID, "POST", rulesEndpoint+"?all_errors=true", "", readerFromConfig(t, cfg))
+			require.Equal(t, http.StatusBadRequest, resp.Code)
+			var found ValidationErrorsView
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &found))
+			assert.Equal(t, "error", found.Status)
+			assert.Equal(t, 5, found.TotalErrors)
+			require.Len(t, found.Errors, tc.expectedErrors)
+			for i, prefix := range []string{
+				"Invalid rules: error parsing a.yml: ",
+				"Invalid rules: error parsing a.yml: ",
+				"Invalid rules: error parsing b.yml: ",
+				"Invalid templates: template: a.tmpl:",
+				"Invalid templates: template: b.tmpl:",
+			}[:tc.expectedErrors] {
+				assert.True(t, strings.HasPrefix(found.Errors[i], prefix), found.Errors[i])
+			}
+
+			// Validation fails fast by default.
+			resp = requestAsUser(t, userID, "POST", rulesEndpoint, "", readerFromConfig(t, cfg))
+			require.Equal(t, http.StatusBadRequest, resp.Code)
+			assert.True(t, strings.HasPrefix(resp.Body.String(), "Invalid rules: "), resp.Body.String())
+
+			// Valid configs are stored as usual.
+			resp = requestAsUser(t, userID, "POST", rulesEndpoint+"?all_errors=true", "", readerFromConfig(t, makeConfig()))
+			assert.Equal(t, http.StatusNoContent, resp.Code, resp.Body.String())
+		})
+	}
+}
+
+func Test_ExportAllConfigs(t *testing.T) {
+	setup(t)
+	defer cleanup(t)
+
+	userID1 := makeUserID()
+	cfg1 := makeConfig()
+	cfg1.RulesConfig.Files = map[string]string{
+		"rules.yml":    "groups:\n- name: a\n  rules:\n  - record: a\n    expr: up\n",
+		"../other.yml": "groups: []\n",
+	}
+	cfg1.TemplateFiles = map[string]string{"my.tmpl": `{{ define "my" }}{{ end }}`}
+	rulesClient.post(t, userID1, cfg1)
+
+	userID2 := makeUserID()
+	cfg2 := makeConfig()
+	rulesClient.post(t, userID2, cfg2)
+
+	// Deleted configs are not exported.
+	userID3 := makeUserID()
+	rulesClient.post(t, userID3, makeConfig())
+	w := requestAsUser(t, userID3, "DELETE", "/api/prom/configs/deactivate", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = request(t, "GET", "/private/api/prom/configs/export-all", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/gzip", w.Header().Get("Content-Type"))
+
+	gr, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	tr := tar.NewReader(gr)
+	var names []string
+	files := map[string]string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+		files[hdr.Name] = string(content)
+	}
+
+	assert.Equal(t, []string{
+		userID1 + "/config.yml",
+		userID1 + "/alertmanager.yml",
+		userID1 + "/rules/%2E.%2Fother.yml",
+		userID1 + "/rules/rules.yml",
+		userID1 + "/templates/my.tmpl",
+		userID2 + "/config.yml",
+		userID2 + "/alertmanager.yml",
+	}, names)
+	assert.Equal(t, "rule_format_version: \"2\"\n", files[userID1+"/config.yml"])
+	assert.Equal(t, cfg1.AlertmanagerConfig, files[userID1+"/alertmanager.yml"])
+	assert.Equal(t, cfg1.RulesConfig.Files["rules.yml"], files[userID1+"/rules/rules.yml"])
+	assert.Equal(t, cfg1.TemplateFiles["my.tmpl"], files[userID1+"/templates/my.tmpl"])
+	assert.Equal(t, cfg2.AlertmanagerConfig, files[userID2+"/alertmanager.yml"])
+}
+
+func Test_SetConfig_TenantExemptions(t *testing.T) {
+	const emailConfig = `
+        global:
+          smtp_smarthost: localhost:25
+          smtp_from: alertmanager@example.org
+        route:
+          receiver: noop
+
+        receivers:
+        - name: noop
+          email_configs:
+          - to: myteam@foobar.org`
+
+	exemptUserID := "exempt"
+	setupWithConfig(t, Config{
+		Notifications: NotificationsConfig{
+			DisableEmail: true,
+		},
+		Validation: ValidationConfig{
+			RuleGroupNamePattern:   "^[a-z]+$",
+			MaxRuleExpressionNodes: 10,
+			TenantExemptions: map[string][]string{
+				exemptUserID: {checkEmailNotifications, checkRules},
+			},
+		},
+	})
+	defer cleanup(t)
+
+	cfg := userconfig.Config{AlertmanagerConfig: emailConfig, RulesConfig: userconfig.RulesConfig{FormatVersion: userconfig.RuleFormatV2}}
+
+	resp := requestAsUser(t, exemptUserID, "POST", alertManagerConfigEndpoint, "", readerFromConfig(t, cfg))
+	assert.Equal(t, http.StatusNoContent, resp.Code, resp.Body.String())
+	resp = requestAsUser(t, exemptUserID, "POST", "/api/prom/configs/alertmanager/validate", "", strings.NewReader(emailConfig))
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	otherUserID := makeUserID()
+	resp = requestAsUser(t, otherUserID, "POST", alertManagerConfigEndpoint, "", readerFromConfig(t, cfg))
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), ErrEmailNotificationsAreDisabled.Error())
+	resp = requestAsUser(t, otherUserID, "POST", "/api/prom/configs/alertmanager/validate", "", strings.NewReader(emailConfig))
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	// Exemptions only apply to the exempted checks.
+	invalid := userconfig.Config{AlertmanagerConfig: "invalid config", RulesConfig: userconfig.RulesConfig{FormatVersion: userconfig.RuleFormatV2}}
+	resp = requestAsUser(t, exemptUserID, "POST", alertManagerConfigEndpoint, "", readerFromConfig(t, invalid))
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	// Exempting a tenant from the rules check lets unparseable rules through,
+	// even though the checks running on parsed rules are enabled.
+	unparseable := makeConfig()
+	unparseable.RulesConfig.Files = map[string]string{"rules.yml": "groups:\n- name: a\n  rules:\n  - record: a\n    expr: sum(\n"}
+	resp = requestAsUser(t, exemptUserID, "POST", rulesEndpoint, "", readerFromConfig(t, unparseable))
+	assert.Equal(t, http.StatusNoContent, resp.Code, resp.Body.String())
+	resp = requestAsUser(t, otherUserID, "POST", rulesEndpoint, "", readerFromConfig(t, unparseable))
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "Invalid rules:")
+}
+
+func TestConfig_Validate_TenantExemptions(t *testing.T) {
+	var cfg Config
+	flagext.DefaultValues(&cfg)
+	cfg.Validation.TenantExemptions = map[string][]string{"user": {checkRules, checkTemplates}}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Validation.TenantExemptions = map[string][]string{"user": {"unknown"}}
+	assert.EqualError(t, cfg.Validate(), `unknown validation check "unknown" exempted for user user, supported checks are: `+strings.Join(validationChecks, ", "))
+}
+
+func Test_SetConfig_DeepTemplateValidation(t *testing.T) {
+	sampleDataFile := path.Join(t.TempDir(), "sample.json")
+	require.NoError(t, os.WriteFile(sampleDataFile, []byte(`{"status": "firing", "alerts": [{"status": "firing", "labels": {"alertname": "Sample"}}]}`), 0o644))
+
+	for name, tc := range map[string]struct {
+		sampleDataFile string
+		text           string
+		templates      map[string]string
+		errContains    string
+	}{
+		"valid templates": {
+			templates: map[string]string{
+				"a.tmpl": `{{ define "a" }}{{ range .Alerts }}{{ .Labels.alertname | toUpper }}{{ end }}{{ template "b" . }}{{ end }}`,
+				"b.tmpl": `{{ define "b" }}{{ .CommonLabels.SortedPairs.Names | join "," }}{{ end }}`,
+			},
+		},
+		"helper called with a list": {
+			templates: map[string]string{
+				"a.tmpl": `{{ define "alert.list" }}{{ range . }}{{ .Labels.alertname }}{{ end }}{{ end }}{{ define "a" }}{{ template "alert.list" .Alerts.Firing }}{{ end }}`,
+			},
+		},
+		"uncalled template": {
+			templates: map[string]string{
+				"a.tmpl": `{{ define "a" }}{{ .Status }}{{ end }}{{ define "unused" }}{{ (index .Alerts 5).Status }}{{ end }}`,
+			},
+		},
+		"markup in text template": {
+			templates: map[string]string{
+				"a.tmpl": `{{ define "a" }}<a href="{{ .ExternalURL }}{{ end }}`,
+			},
+		},
+		"inline template": {
+			text:        `{{ (index .Alerts 5).Status }}`,
+			errContains: `Invalid templates: executing template "{{ (index .Alerts 5).Status }}" of the Alertmanager config:`,
+		},
+		"runtime error": {
+			templates: map[string]string{
+				"a.tmpl": `{{ define "a" }}{{ (index .Alerts 5).Status }}{{ end }}`,
+			},
+			errContains: `Invalid templates: executing template "{{ template \"a\" . }}" of the Alertmanager config: template: a.tmpl:1:`,
+		},
+		"wrong argument type": {
+			templates: map[string]string{
+				"a.tmpl": `{{ define "a" }}{{ .Alerts | toUpper }}{{ end }}`,
+			},
+			errContains: `executing "a"`,
+		},
+		"runtime error with configured sample data": {
+			sampleDataFile: sampleDataFile,
+			templates: map[string]string{
+				"a.tmpl": `{{ define "a" }}{{ (index .Alerts 1).Status }}{{ end }}`,
+			},
+			errContains: `executing "a"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			setupWithConfig(t, Config{Validation: ValidationConfig{TemplateSampleDataFile: tc.sampleDataFile}})
+			defer cleanup(t)
+
+			text := tc.text
+			if text == "" {
+				text = `{{ template "a" . }}`
+			}
+			cfg := makeConfig()
+			cfg.AlertmanagerConfig = fmt.Sprintf(`
+route:
+  receiver: noop
+receivers:
+- name: noop
+  slack_configs:
+  - api_url: http://slack
+    text: '%s'
+`, text)
+			cfg.TemplateFiles = tc.templates
+			userID := makeUserID()
+
+			// Templates are only executed on request.
+			resp := requestAsUser(t, userID, "POST", "/api/prom/configs/templates", "", readerFromConfig(t, cfg))
+			require.Equal(t, http.StatusNoContent, resp.Code, resp.Body.String())
+
+			resp = requestAsUser(t, userID, "POST", "/api/prom/configs/templates?deep=true", "", readerFromConfig(t, cfg))
+			if tc.errContains == "" {
+				assert.Equal(t, http.StatusNoContent, resp.Code, resp.Body.String())
+				return
+			}
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			assert.Contains(t, resp.Body.String(), tc.errContains)
+		})
+	}
+}
+
+func Test_New_InvalidTemplateSampleData(t *testing.T) {
+	sampleDataFile := path.Join(t.TempDir(), "sample.json")
+	require.NoError(t, os.WriteFile(sampleDataFile, []byte(`{"alerts": {}}`), 0o644))
+	_, err := New(nil, Config{Validation: ValidationConfig{TemplateSampleDataFile: sampleDataFile}})
+	assert.Error(t, err)
+	_, err = New(nil, Config{Validation: ValidationConfig{TemplateSampleDataFile: path.Join(t.TempDir(), "missing.json")}})
+	assert.Error(t, err)
+}
+
+func Test_GetOrphanedTemplates(t *testing.T) {
+	setup(t)
+	defer cleanup(t)
+
+	const orphanedTemplatesEndpoint = "/api/prom/configs/alertmanager/orphaned-templates"
+
+	userID := makeUserID()
+	w := requestAsUser(t, userID, "GET", orphanedTemplatesEndpoint, "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	cfg := userconfig.Config{
+		AlertmanagerConfig: `
+templates:
+- 'glob-*.tmpl'
+route:
+  receiver: slack
+receivers:
+- name: slack
+  slack_configs:
+  - api_url: http://slack
+    text: "{{ template \"slack.text\" . }}"`,
+		RulesConfig: userconfig.RulesConfig{FormatVersion: userconfig.RuleFormatV2},
+		TemplateFiles: map[string]string{
+			"glob-a.tmpl":     `{{ define "glob" }}{{ end }}`,
+			"called.tmpl":     `{{ define "slack.text" }}{{ .Status }}{{ template "slack.footer" . }}{{ end }}`,
+			"transitive.tmpl": `{{ define "slack.footer" }}footer{{ end }}`,
+			"orphan-b.tmpl":   `{{ define "unused.b" }}{{ end }}`,
+			"orphan-a.tmpl":   `{{ define "unused.a" }}{{ template "unused.b" . }}{{ end }}`,
+		},
+	}
+	alertManagerConfigClient.post(t, userID, cfg)
+
+	w = requestAsUser(t, userID, "GET", orphanedTemplatesEndpoint, "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"orphaned_templates": ["orphan-a.tmpl", "orphan-b.tmpl"]}`, w.Body.String())
+
+	delete(cfg.TemplateFiles, "orphan-a.tmpl")
+	delete(cfg.TemplateFiles, "orphan-b.tmpl")
+	alertManagerConfigClient.post(t, userID, cfg)
+
+	w = requestAsUser(t, userID, "GET", orphanedTemplatesEndpoint, "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"orphaned_templates": []}`, w.Body.String())
+}
+
+func Test_GetConfigBundle(t *testing.T) {
+	setup(t)
+	defer cleanup(t)
+
+	const bundleEndpoint = "/api/prom/configs/all"
+
+	userID := makeUserID()
+	w := requestAsUser(t, userID, "GET", bundleEndpoint, "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// A config with neither rules nor an Alertmanager config doesn't count.
+	emptyCfg := userconfig.Config{RulesConfig: userconfig.RulesConfig{FormatVersion: userconfig.RuleFormatV2}}
+	rulesClient.post(t, userID, emptyCfg)
+	w = requestAsUser(t, userID, "GET", bundleEndpoint, "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	cfg := makeConfig()
+	cfg.RulesConfig.Files = map[string]string{"rules.yml": "groups:\n- name: a\n  rules:\n  - record: a\n    expr: up\n"}
+	cfg.TemplateFiles = map[string]string{"my.tmpl": `{{ define "my" }}{{ end }}`}
+	view := rulesClient.post(t, userID, cfg)
+
+	w = requestAsUser(t, userID, "GET", bundleEndpoint, "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, view, parseView(t, w.Body.Bytes()))
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// errDuplicateKey is returned when a posted config body defines the same key
+// twice in one object. Both encoding/json and yaml.v2 silently keep the last
+// value, which hides mistakes such as two `alertmanager_config` keys.
+type errDuplicateKey struct {
+	path string
+}
+
+func (e errDuplicateKey) Error() string {
+	return fmt.Sprintf("duplicate key %q", e.path)
+}
+
+// checkDuplicateJSONKeys returns an errDuplicateKey for the first key found
+// twice in the same JSON object of data. The top-level object is decoded into
+// struct fields, which encoding/json matches case-insensitively, so its keys
+// are compared regardless of case. Nested objects are decoded into maps, whose
+// keys are compared exactly.
+func checkDuplicateJSONKeys(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err, ok := walkJSONValue(dec, "", true).(errDuplicateKey); ok {
+		return err
+	}
+	// Leave reporting syntax errors to the decoder.
+	return nil
+}
+
+func walkJSONValue(dec *json.Decoder, path string, foldCase bool) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('{'):
+		seen := map[string]struct{}{}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key, ok := tok.(string)
+			if !ok {
+				return fmt.Errorf("unexpected JSON token %v", tok)
+			}
+			keyPath := joinKeyPath(path, key)
+			seenKey := key
+			if foldCase {
+				seenKey = strings.ToLower(key)
+			}
+			if _, ok := seen[seenKey]; ok {
+				return errDuplicateKey{path: keyPath}
+			}
+			seen[seenKey] = struct{}{}
+			if err := walkJSONValue(dec, keyPath, false); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+		return err
+	case json.Delim('['):
+		for i := 0; dec.More(); i++ {
+			if err := walkJSONValue(dec, path+"["+strconv.Itoa(i)+"]", false); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+		return err
+	}
+	return nil
+}
+
+// checkDuplicateYAMLKeys returns an errDuplicateKey for the first key found
+// twice in the same YAML mapping of data.
+func checkDuplicateYAMLKeys(data []byte) error {
+	var node yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &node); err != nil {
+		// Leave reporting syntax errors to the decoder.
+		return nil
+	}
+	return walkYAMLNode(&node, "")
+}
+
+func walkYAMLNode(node *yamlv3.Node, path string) error {
+	switch node.Kind {
+	case yamlv3.DocumentNode:
+		for _, n := range node.Content {
+			if err := walkYAMLNode(n, path); err != nil {
+				return err
+			}
+		}
+	case yamlv3.MappingNode:
+		seen := map[string]struct{}{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			keyPath := joinKeyPath(path, key)
+			if _, ok := seen[key]; ok {
+				return errDuplicateKey{path: keyPath}
+			}
+			seen[key] = struct{}{}
+			if err := walkYAMLNode(node.Content[i+1], keyPath); err != nil {
+				return err
+			}
+		}
+	case yamlv3.SequenceNode:
+		for i, n := range node.Content {
+			if err := walkYAMLNode(n, path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func joinKeyPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-kit/log/level"
+
+	"github.com/cortexproject/cortex/pkg/configs/userconfig"
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util"
+	util_log "github.com/cortexproject/cortex/pkg/util/log"
+)
+
+// getRulesEvaluationStatus returns the health of the requesting user's rules
+// last reported by the rulers, or no groups when nothing was reported yet.
+// Groups that are no longer part of the user's rules are left out.
+func (a *API) getRulesEvaluationStatus(w http.ResponseWriter, r *http.Request) {
+	userID, _, err := tenant.ExtractTenantIDFromHTTPRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	logger := util_log.WithContext(r.Context(), util_log.Logger)
+
+	status, err := a.db.GetRulesEvaluationStatus(r.Context(), userID)
+	if err != nil && err != sql.ErrNoRows {
+		// XXX: Untested
+		level.Error(logger).Log("msg", "error getting rules evaluation status", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	groups, err := a.evaluatedRuleGroups(r.Context(), userID)
+	if err != nil {
+		level.Warn(logger).Log("msg", "error getting evaluated rule groups, not filtering the rules evaluation status", "err", err)
+	}
+	current := []userconfig.RuleGroupEvaluationStatus{}
+	for _, group := range status.Groups {
+		if _, ok := groups[ruleGroupKey{file: group.File, name: group.Name}]; ok || err != nil {
+			current = append(current, group)
+		}
+	}
+	status.Groups = current
+
+	util.WriteJSONResponse(w, status)
+}
+
+// setRulesEvaluationStatus stores the health of some of a user's rule groups,
+// as reported by a ruler after evaluating them. It replaces the previously
+// reported health of these groups only, as the groups of a user can be
+// evaluated by several rulers.
+func (a *API) setRulesEvaluationStatus(w http.ResponseWriter, r *http.Request) {
+	userID, _, err := tenant.ExtractTenantIDFromHTTPRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	logger := util_log.WithContext(r.Context(), util_log.Logger)
+
+	var status userconfig.RulesEvaluationStatus
+	if err := json.NewDecoder(r.Body).Decode(&status); err != nil {
+		level.Error(logger).Log("msg", "error decoding rules evaluation status", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := a.db.SetRulesEvaluationStatus(r.Context(), userID, status); err != nil {
+		// XXX: Untested
+		level.Error(logger).Log("msg", "error storing rules evaluation status", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type ruleGroupKey struct {
+	file, name string
+}
+
+// evaluatedRuleGroups returns the rule groups evaluated for a user, by file
+// and name, none if the user has no config.
+func (a *API) evaluatedRuleGroups(ctx context.Context, userID string) (map[ruleGroupKey]struct{}, error) {
+	groups := map[ruleGroupKey]struct{}{}
+	cfg, err := a.activeConfig(ctx, userID)
+	if err == sql.ErrNoRows {
+		return groups, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rgs, err := cfg.Config.RulesConfig.ParseFormatted()
+	if err != nil {
+		return nil, err
+	}
+	for file, fileGroups := range rgs {
+		for _, group := range fileGroups.Groups {
+			groups[ruleGroupKey{file: file, name: group.Name}] = struct{}{}
+		}
+	}
+	return groups, nil
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// Names of the validation checks which operators can skip for specific
+// tenants, see ValidationConfig.TenantExemptions.
+const (
+	checkAlertmanagerConfig     = "alertmanager_config"
+	checkEmailNotifications     = "email_notifications"
+	checkWebhookNotifications   = "webhook_notifications"
+	checkAlertmanagerReloadCost = "alertmanager_reload_cost"
+	checkRules                  = "rules"
+	checkRuleGroupNames         = "rule_group_names"
+	checkRuleExpressions        = "rule_expressions"
+	checkTemplates              = "templates"
+	checkExternalLabels         = "external_labels"
+	checkIneffectiveContinue    = "ineffective_continue"
+)
+
+var validationChecks = []string{
+	checkAlertmanagerConfig,
+	checkEmailNotifications,
+	checkWebhookNotifications,
+	checkAlertmanagerReloadCost,
+	checkRules,
+	checkRuleGroupNames,
+	checkRuleExpressions,
+	checkTemplates,
+	checkExternalLabels,
+	checkIneffectiveContinue,
+}
+
+func validateTenantExemptions(exemptions map[string][]string) error {
+	for userID, checks := range exemptions {
+		for _, check := range checks {
+			if !isValidationCheck(check) {
+				return fmt.Errorf("unknown validation check %q exempted for user %s, supported checks are: %s", check, userID, strings.Join(validationChecks, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+func isValidationCheck(check string) bool {
+	for _, c := range validationChecks {
+		if c == check {
+			return true
+		}
+	}
+	return false
+}
+
+// exemptions is the set of validation checks skipped for a single user. The
+// zero value skips nothing.
+type exemptions struct {
+	logger log.Logger
+	userID string
+	checks map[string]struct{}
+}
+
+// exemptionsFor returns the validation checks skipped for the given user.
+func (a *API) exemptionsFor(logger log.Logger, userID string) exemptions {
+	ex := exemptions{logger: logger, userID: userID}
+	if checks := a.cfg.Validation.TenantExemptions[userID]; len(checks) > 0 {
+		ex.checks = make(map[string]struct{}, len(checks))
+		for _, check := range checks {
+			ex.checks[check] = struct{}{}
+		}
+	}
+	return ex
+}
+
+// skip returns whether the given check, which failed, must be ignored because
+// the user is exempt from it. It must only be called once the check failed,
+// so that each applied exemption is logged.
+func (e exemptions) skip(check string) bool {
+	if _, ok := e.checks[check]; !ok {
+		return false
+	}
+	level.Info(e.logger).Log("msg", "skipping failed validation check, tenant is exempt", "userID", e.userID, "check", check)
+	return true
+}
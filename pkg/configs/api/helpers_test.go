@@ -26,23 +26,28 @@ var (
 
 // setup sets up the environment for the tests.
 func setup(t *testing.T) {
-	database = dbtest.Setup(t)
-	app = New(database, Config{
+	setupWithConfig(t, Config{
 		Notifications: NotificationsConfig{
 			DisableEmail: true,
 		},
 	})
-	counter = 0
 }
 
 // setup sets up the environment for the tests with email enabled.
 func setupWithEmailEnabled(t *testing.T) {
-	database = dbtest.Setup(t)
-	app = New(database, Config{
+	setupWithConfig(t, Config{
 		Notifications: NotificationsConfig{
 			DisableEmail: false,
 		},
 	})
+}
+
+// setupWithConfig sets up the environment for the tests with the given API config.
+func setupWithConfig(t *testing.T, cfg Config) {
+	var err error
+	database = dbtest.Setup(t)
+	app, err = New(database, cfg)
+	require.NoError(t, err)
 	counter = 0
 }
 
@@ -100,6 +105,22 @@ func makeConfig() userconfig.Config {
 	}
 }
 
+// getAsUser makes a GET request as the given user, accepting the given
+// content type.
+func getAsUser(t *testing.T, userID string, urlStr string, accept string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest("GET", urlStr, nil)
+	require.NoError(t, err)
+	r = r.WithContext(user.InjectOrgID(r.Context(), userID))
+	err = user.InjectOrgIDIntoHTTPRequest(r.Context(), r)
+	require.NoError(t, err)
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	app.ServeHTTP(w, r)
+	return w
+}
+
 func readerFromConfig(t *testing.T, config userconfig.Config) io.Reader {
 	b, err := json.Marshal(config)
 	require.NoError(t, err)
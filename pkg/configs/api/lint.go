@@ -0,0 +1,141 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+
+	amconfig "github.com/prometheus/alertmanager/config"
+	amlabels "github.com/prometheus/alertmanager/pkg/labels"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/cortexproject/cortex/pkg/configs/userconfig"
+)
+
+// Lints are warning-level checks: they never cause a config to be rejected,
+// and are reported by the validation endpoints alongside the result.
+
+// externalLabelsWarnings cross-checks the labels Alertmanager routes match on
+// against the configured external labels and the labels set by the alerting
+// rules. A route which matches on an external label, directly or through one
+// of its parents, is expected to route alerts generated by this deployment:
+// its matchers on external labels must accept the external label values, and
+// every other label it matches on should be set by some alerting rule.
+//
+// The rules config is optional; without it only the external label values
+// are checked.
+func externalLabelsWarnings(amCfg *amconfig.Config, rulesCfg *userconfig.RulesConfig, externalLabels labels.Labels) []string {
+	if len(externalLabels) == 0 || amCfg.Route == nil {
+		return nil
+	}
+
+	var ruleLabels map[string]struct{}
+	if rulesCfg != nil {
+		// Rules errors are reported by the rules validation, not here.
+		ruleLabels, _ = alertingRuleLabelNames(*rulesCfg)
+	}
+
+	var warnings []string
+	var walk func(r *amconfig.Route, path string, scoped bool)
+	walk = func(r *amconfig.Route, path string, scoped bool) {
+		matchers := routeMatchers(r)
+		for _, m := range matchers {
+			if v := externalLabels.Get(m.Name); v != "" {
+				scoped = true
+				if !m.Matches(v) {
+					warnings = append(warnings, fmt.Sprintf("%s matches external label %s, which never matches the external label value %q", path, m.String(), v))
+				}
+			}
+		}
+		if scoped && ruleLabels != nil {
+			for _, m := range matchers {
+				if externalLabels.Has(m.Name) {
+					continue
+				}
+				if _, ok := ruleLabels[m.Name]; !ok {
+					warnings = append(warnings, fmt.Sprintf("%s matches external labels and label %q, but no alerting rule sets %q", path, m.Name, m.Name))
+				}
+			}
+		}
+		for i, child := range r.Routes {
+			walk(child, fmt.Sprintf("%s.routes[%d]", path, i), scoped)
+		}
+	}
+	walk(amCfg.Route, "route", false)
+
+	return warnings
+}
+
+// ineffectiveContinueWarnings reports leaf routes setting `continue: true`
+// without any sibling route after them. Once such a route matched there is no
+// other route left to continue matching against, so the flag has no effect and
+// usually means a route was expected to follow it.
+func ineffectiveContinueWarnings(amCfg *amconfig.Config) []string {
+	if amCfg.Route == nil {
+		return nil
+	}
+
+	var warnings []string
+	var walk func(r *amconfig.Route, path string)
+	walk = func(r *amconfig.Route, path string) {
+		for i, child := range r.Routes {
+			childPath := fmt.Sprintf("%s.routes[%d]", path, i)
+			if child.Continue && len(child.Routes) == 0 && i == len(r.Routes)-1 {
+				warnings = append(warnings, fmt.Sprintf("%s sets continue: true, but has neither child routes nor sibling routes after it to continue to", childPath))
+			}
+			walk(child, childPath)
+		}
+	}
+	walk(amCfg.Route, "route")
+
+	return warnings
+}
+
+// routeMatchers returns the matchers of a route, including the ones expressed
+// with the deprecated match and match_re fields.
+func routeMatchers(r *amconfig.Route) amlabels.Matchers {
+	matchers := append(amlabels.Matchers{}, r.Matchers...)
+	for _, name := range sortedKeys(r.Match) {
+		if m, err := amlabels.NewMatcher(amlabels.MatchEqual, name, r.Match[name]); err == nil {
+			matchers = append(matchers, m)
+		}
+	}
+	for name, re := range r.MatchRE {
+		if m, err := amlabels.NewMatcher(amlabels.MatchRegexp, name, re.String()); err == nil {
+			matchers = append(matchers, m)
+		}
+	}
+	sort.Sort(matchers)
+	return matchers
+}
+
+// alertingRuleLabelNames returns the names of all the labels explicitly set
+// by the alerting rules of a rules config.
+func alertingRuleLabelNames(c userconfig.RulesConfig) (map[string]struct{}, error) {
+	rgs, err := c.ParseFormatted()
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]struct{}{}
+	for _, groups := range rgs {
+		for _, rg := range groups.Groups {
+			for _, rl := range rg.Rules {
+				if rl.Alert.Value == "" {
+					continue
+				}
+				for name := range rl.Labels {
+					names[name] = struct{}{}
+				}
+			}
+		}
+	}
+	return names, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,266 @@
+package api
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	amconfig "github.com/prometheus/alertmanager/config"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cortexproject/cortex/pkg/configs/userconfig"
+)
+
+// mergedNamedLists are the top-level Alertmanager config lists whose entries
+// are identified by their name.
+var mergedNamedLists = []string{"receivers", "time_intervals", "mute_time_intervals"}
+
+// mergedLists are the top-level Alertmanager config lists whose entries are
+// merged as a whole.
+var mergedLists = []string{"templates", "inhibit_rules"}
+
+// mergeAlertmanagerConfigs merges the Alertmanager configs and template files
+// of two tenants, named a and b, returning the merged config along with the
+// conflicts found. The merge works on the configs as written, so secrets and
+// omitted defaults are kept as they are, and follows these rules:
+//
+//   - Receivers, time intervals and mute time intervals are the ones of a,
+//     followed by the ones of b whose name isn't used by a. Entries defined by
+//     both with a different definition are a conflict, and a's is kept.
+//   - Templates and inhibit rules are the ones of a, followed by the ones of b
+//     not also defined by a.
+//   - The root route is a's, with b's child routes appended after a's. Any
+//     other setting of b's root route that differs from a's is a conflict, and
+//     a's is kept: alerts not matching any child route now go to a's default
+//     receiver. A child route of a overlaps a child route of b when it matches
+//     all the alerts matched by b's, that is when its matchers are a subset of
+//     b's: unless it sets continue, b's route is then never reached and this
+//     is a conflict.
+//   - Any other top-level setting, such as global, is a's, or b's when not set
+//     by a. Settings defined differently by both are a conflict.
+//   - Template files are the ones of a, followed by the ones of b whose name
+//     isn't used by a. Files with the same name and a different content are a
+//     conflict, and a's is kept.
+//
+// Conflicts are sorted, and the merged config being invalid is a conflict too.
+func mergeAlertmanagerConfigs(a, b string, cfgA, cfgB userconfig.Config) (userconfig.Config, []string, error) {
+	var rawA, rawB yaml.MapSlice
+	if err := yaml.Unmarshal([]byte(cfgA.AlertmanagerConfig), &rawA); err != nil {
+		return userconfig.Config{}, nil, fmt.Errorf("invalid Alertmanager config of %s: %w", a, err)
+	}
+	if err := yaml.Unmarshal([]byte(cfgB.AlertmanagerConfig), &rawB); err != nil {
+		return userconfig.Config{}, nil, fmt.Errorf("invalid Alertmanager config of %s: %w", b, err)
+	}
+	amA, err := amconfig.Load(cfgA.AlertmanagerConfig)
+	if err != nil {
+		return userconfig.Config{}, nil, fmt.Errorf("invalid Alertmanager config of %s: %w", a, err)
+	}
+	amB, err := amconfig.Load(cfgB.AlertmanagerConfig)
+	if err != nil {
+		return userconfig.Config{}, nil, fmt.Errorf("invalid Alertmanager config of %s: %w", b, err)
+	}
+
+	m := &configMerger{a: a, b: b}
+	merged := yaml.MapSlice{}
+	for _, item := range rawA {
+		key := item.Key.(string)
+		valueB, inB := mapSliceValue(rawB, key)
+		switch {
+		case !inB:
+			merged = append(merged, item)
+		case key == "route":
+			merged = append(merged, yaml.MapItem{Key: key, Value: m.mergeRoutes(item.Value, valueB, amA.Route, amB.Route)})
+		case contains(mergedNamedLists, key):
+			merged = append(merged, yaml.MapItem{Key: key, Value: m.mergeNamedList(key, item.Value, valueB)})
+		case contains(mergedLists, key):
+			merged = append(merged, yaml.MapItem{Key: key, Value: m.mergeList(item.Value, valueB)})
+		default:
+			if !reflect.DeepEqual(item.Value, valueB) {
+				m.conflict("%s is defined differently by %s and %s, the one of %s is kept", key, a, b, a)
+			}
+			merged = append(merged, item)
+		}
+	}
+	for _, item := range rawB {
+		if _, ok := mapSliceValue(rawA, item.Key.(string)); !ok {
+			merged = append(merged, item)
+		}
+	}
+
+	out, err := yaml.Marshal(merged)
+	if err != nil {
+		return userconfig.Config{}, nil, err
+	}
+	cfg := userconfig.Config{
+		AlertmanagerConfig: string(out),
+		TemplateFiles:      m.mergeTemplateFiles(cfgA.TemplateFiles, cfgB.TemplateFiles),
+	}
+	if _, err := amconfig.Load(cfg.AlertmanagerConfig); err != nil {
+		m.conflict("the merged Alertmanager config is invalid: %v", err)
+	}
+
+	sort.Strings(m.conflicts)
+	return cfg, m.conflicts, nil
+}
+
+type configMerger struct {
+	a, b      string
+	conflicts []string
+}
+
+func (m *configMerger) conflict(format string, args ...interface{}) {
+	m.conflicts = append(m.conflicts, fmt.Sprintf(format, args...))
+}
+
+func (m *configMerger) mergeRoutes(rawA, rawB interface{}, routeA, routeB *amconfig.Route) interface{} {
+	msA, okA := rawA.(yaml.MapSlice)
+	msB, okB := rawB.(yaml.MapSlice)
+	if !okA || !okB || routeA == nil || routeB == nil {
+		return rawA
+	}
+
+	merged := yaml.MapSlice{}
+	for _, item := range msA {
+		if item.Key == "routes" {
+			continue
+		}
+		merged = append(merged, item)
+	}
+	for _, item := range msB {
+		key := item.Key.(string)
+		if key == "routes" {
+			continue
+		}
+		if valueA, ok := mapSliceValue(msA, key); !ok || !reflect.DeepEqual(valueA, item.Value) {
+			m.conflict("route.%s of %s differs from the one of %s, which is kept", key, m.b, m.a)
+		}
+	}
+
+	childrenA, _ := mapSliceValue(msA, "routes")
+	childrenB, _ := mapSliceValue(msB, "routes")
+	children := append(toSlice(childrenA), toSlice(childrenB)...)
+	if len(children) > 0 {
+		merged = append(merged, yaml.MapItem{Key: "routes", Value: children})
+	}
+
+	for j, rb := range routeB.Routes {
+		matchersB := matcherStrings(rb)
+		for i, ra := range routeA.Routes {
+			if ra.Continue || !isSubset(matcherStrings(ra), matchersB) {
+				continue
+			}
+			m.conflict("route.routes[%d] of %s overlaps route.routes[%d] of %s, which it shadows", i, m.a, j, m.b)
+			break
+		}
+	}
+	return merged
+}
+
+func (m *configMerger) mergeNamedList(key string, rawA, rawB interface{}) interface{} {
+	listA := toSlice(rawA)
+	merged := append([]interface{}{}, listA...)
+	for _, entryB := range toSlice(rawB) {
+		name := entryName(entryB)
+		found := false
+		for _, entryA := range listA {
+			if name == "" || entryName(entryA) != name {
+				continue
+			}
+			found = true
+			if !reflect.DeepEqual(entryA, entryB) {
+				m.conflict("%s entry %q is defined differently by %s and %s, the one of %s is kept", key, name, m.a, m.b, m.a)
+			}
+			break
+		}
+		if !found {
+			merged = append(merged, entryB)
+		}
+	}
+	return merged
+}
+
+func (m *configMerger) mergeList(rawA, rawB interface{}) interface{} {
+	listA := toSlice(rawA)
+	merged := append([]interface{}{}, listA...)
+	for _, entryB := range toSlice(rawB) {
+		found := false
+		for _, entryA := range listA {
+			if reflect.DeepEqual(entryA, entryB) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, entryB)
+		}
+	}
+	return merged
+}
+
+func (m *configMerger) mergeTemplateFiles(filesA, filesB map[string]string) map[string]string {
+	if filesA == nil && filesB == nil {
+		return nil
+	}
+	merged := map[string]string{}
+	for name, content := range filesA {
+		merged[name] = content
+	}
+	for name, content := range filesB {
+		contentA, ok := filesA[name]
+		if !ok {
+			merged[name] = content
+		} else if contentA != content {
+			m.conflict("template file %q is defined differently by %s and %s, the one of %s is kept", name, m.a, m.b, m.a)
+		}
+	}
+	return merged
+}
+
+func mapSliceValue(ms yaml.MapSlice, key string) (interface{}, bool) {
+	for _, item := range ms {
+		if item.Key == key {
+			return item.Value, true
+		}
+	}
+	return nil, false
+}
+
+func toSlice(v interface{}) []interface{} {
+	s, _ := v.([]interface{})
+	return s
+}
+
+func entryName(entry interface{}) string {
+	ms, _ := entry.(yaml.MapSlice)
+	name, _ := mapSliceValue(ms, "name")
+	s, _ := name.(string)
+	return s
+}
+
+func matcherStrings(r *amconfig.Route) []string {
+	matchers := routeMatchers(r)
+	s := make([]string, 0, len(matchers))
+	for _, m := range matchers {
+		s = append(s, m.String())
+	}
+	return s
+}
+
+// isSubset tells whether all the elements of a are in b.
+func isSubset(a, b []string) bool {
+	for _, s := range a {
+		if !contains(b, s) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cortexproject/cortex/pkg/configs/userconfig"
+)
+
+// strictMarker opts a config into strict validation when found as a comment
+// line, such as `# cortex:strict`, of its Alertmanager config or of any of its
+// rules files.
+const strictMarker = "cortex:strict"
+
+// isStrict tells whether warnings are validation errors for a config, either
+// because strict validation is enabled for all configs or because the config
+// opted into it with the strict marker. The marker can only raise strictness.
+func (a *API) isStrict(amCfg string, rulesCfg *userconfig.RulesConfig) bool {
+	if a.cfg.Validation.Strict || hasStrictMarker(amCfg) {
+		return true
+	}
+	if rulesCfg != nil {
+		for _, content := range rulesCfg.Files {
+			if hasStrictMarker(content) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasStrictMarker(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#") && strings.TrimSpace(strings.TrimPrefix(line, "#")) == strictMarker {
+			return true
+		}
+	}
+	return false
+}
+
+func strictValidationError(warnings []string) error {
+	return fmt.Errorf("warnings are errors with strict validation: %s", strings.Join(warnings, "; "))
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+
+	amconfig "github.com/prometheus/alertmanager/config"
+
+	"github.com/cortexproject/cortex/pkg/configs/userconfig"
+)
+
+// configStructure is the shape of a config: the names of its rule groups and
+// receivers, regardless of their contents.
+type configStructure struct {
+	ruleGroups map[string]struct{}
+	receivers  map[string]struct{}
+}
+
+// parseConfigStructure returns the structure of a config. Rule groups are
+// identified by their name and the rules file defining them.
+func parseConfigStructure(c userconfig.Config) (configStructure, error) {
+	s := configStructure{
+		ruleGroups: map[string]struct{}{},
+		receivers:  map[string]struct{}{},
+	}
+
+	if c.RulesConfig.Files != nil {
+		rgs, err := c.RulesConfig.ParseFormatted()
+		if err != nil {
+			return s, fmt.Errorf("invalid rules: %w", err)
+		}
+		for fn, groups := range rgs {
+			for _, rg := range groups.Groups {
+				s.ruleGroups[fmt.Sprintf("%s (%s)", rg.Name, fn)] = struct{}{}
+			}
+		}
+	}
+
+	if c.AlertmanagerConfig != "" {
+		amCfg, err := amconfig.Load(c.AlertmanagerConfig)
+		if err != nil {
+			return s, fmt.Errorf("invalid Alertmanager config: %w", err)
+		}
+		for _, recv := range amCfg.Receivers {
+			s.receivers[recv.Name] = struct{}{}
+		}
+	}
+
+	return s, nil
+}
+
+// StructureDiff lists the names present in only one of two compared configs.
+type StructureDiff struct {
+	// Missing are present in the reference config but not in the candidate.
+	Missing []string `json:"missing"`
+	// Extra are present in the candidate config but not in the reference.
+	Extra []string `json:"extra"`
+}
+
+// ConfigStructureView renders the structural differences between a candidate
+// config and a reference config.
+// Exposed only for tests.
+type ConfigStructureView struct {
+	Matches    bool          `json:"matches"`
+	RuleGroups StructureDiff `json:"rule_groups"`
+	Receivers  StructureDiff `json:"receivers"`
+}
+
+func diffConfigStructure(candidate, reference configStructure) ConfigStructureView {
+	view := ConfigStructureView{
+		RuleGroups: diffNames(candidate.ruleGroups, reference.ruleGroups),
+		Receivers:  diffNames(candidate.receivers, reference.receivers),
+	}
+	view.Matches = len(view.RuleGroups.Missing) == 0 && len(view.RuleGroups.Extra) == 0 &&
+		len(view.Receivers.Missing) == 0 && len(view.Receivers.Extra) == 0
+	return view
+}
+
+func diffNames(candidate, reference map[string]struct{}) StructureDiff {
+	diff := StructureDiff{Missing: []string{}, Extra: []string{}}
+	for name := range reference {
+		if _, ok := candidate[name]; !ok {
+			diff.Missing = append(diff.Missing, name)
+		}
+	}
+	for name := range candidate {
+		if _, ok := reference[name]; !ok {
+			diff.Extra = append(diff.Extra, name)
+		}
+	}
+	sort.Strings(diff.Missing)
+	sort.Strings(diff.Extra)
+	return diff
+}
@@ -0,0 +1,344 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"text/template/parse"
+	"time"
+
+	amconfig "github.com/prometheus/alertmanager/config"
+	amtemplate "github.com/prometheus/alertmanager/template"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cortexproject/cortex/pkg/configs/userconfig"
+)
+
+// templateFunctions returns the names of all functions called by the
+// templates in the given config, both in template files and inline in the
+// Alertmanager config.
+func templateFunctions(c userconfig.Config) (map[string]struct{}, error) {
+	var templates []*template.Template
+	for fn, content := range c.TemplateFiles {
+		t, err := parseTemplateFile(fn, content)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	inline, err := inlineTemplates(c.AlertmanagerConfig)
+	if err != nil {
+		return nil, err
+	}
+	templates = append(templates, inline...)
+
+	funcs := map[string]struct{}{}
+	for _, t := range templates {
+		walkTemplate(t, func(node parse.Node) {
+			if n, ok := node.(*parse.IdentifierNode); ok {
+				funcs[n.Ident] = struct{}{}
+			}
+		})
+	}
+	return funcs, nil
+}
+
+// inlineTemplates returns the templates written inline in the string values
+// of an Alertmanager config, such as notification texts.
+func inlineTemplates(amCfg string) ([]*template.Template, error) {
+	texts, err := inlineTemplateTexts(amCfg)
+	if err != nil {
+		return nil, err
+	}
+	var templates []*template.Template
+	for _, text := range texts {
+		t, err := template.New("").Funcs(template.FuncMap(amtemplate.DefaultFuncs)).Parse(text)
+		if err != nil {
+			// Not a template, or one the Alertmanager would already reject.
+			continue
+		}
+		templates = append(templates, t)
+	}
+	return templates, nil
+}
+
+// orphanedTemplates returns the sorted names of the template files which are
+// neither matched by a `templates` glob of the Alertmanager config nor define
+// a template called, directly or through other templates, by the Alertmanager
+// config.
+func orphanedTemplates(c userconfig.Config) ([]string, error) {
+	var amCfg *amconfig.Config
+	if c.AlertmanagerConfig != "" {
+		var err error
+		if amCfg, err = amconfig.Load(c.AlertmanagerConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	// Templates defined and called by each file.
+	defines := map[string][]string{}
+	calls := map[string][]string{}
+	for fn, content := range c.TemplateFiles {
+		t, err := parseTemplateFile(fn, content)
+		if err != nil {
+			return nil, err
+		}
+		for _, tmpl := range t.Templates() {
+			if tmpl.Name() != fn {
+				defines[tmpl.Name()] = append(defines[tmpl.Name()], fn)
+			}
+		}
+		calls[fn] = calledTemplates(t)
+	}
+
+	referenced := map[string]bool{}
+	var pending []string
+	reference := func(fn string) {
+		if !referenced[fn] {
+			referenced[fn] = true
+			pending = append(pending, calls[fn]...)
+		}
+	}
+
+	if amCfg != nil {
+		for fn := range c.TemplateFiles {
+			for _, glob := range amCfg.Templates {
+				if ok, _ := path.Match(glob, fn); ok {
+					reference(fn)
+				}
+			}
+		}
+		inline, err := inlineTemplates(c.AlertmanagerConfig)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range inline {
+			pending = append(pending, calledTemplates(t)...)
+		}
+	}
+
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		for _, fn := range defines[name] {
+			reference(fn)
+		}
+	}
+
+	orphaned := []string{}
+	for fn := range c.TemplateFiles {
+		if !referenced[fn] {
+			orphaned = append(orphaned, fn)
+		}
+	}
+	sort.Strings(orphaned)
+	return orphaned, nil
+}
+
+// calledTemplates returns the names of the templates invoked with the
+// `template` action by a template.
+func calledTemplates(t *template.Template) []string {
+	var names []string
+	walkTemplate(t, func(node parse.Node) {
+		if n, ok := node.(*parse.TemplateNode); ok {
+			names = append(names, n.Name)
+		}
+	})
+	return names
+}
+
+// yamlStrings returns all the string values of a decoded YAML document.
+func yamlStrings(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var out []string
+		for _, e := range v {
+			out = append(out, yamlStrings(e)...)
+		}
+		return out
+	case map[interface{}]interface{}:
+		var out []string
+		for _, e := range v {
+			out = append(out, yamlStrings(e)...)
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+// walkTemplate calls visit for every node of all the templates associated
+// with t.
+func walkTemplate(t *template.Template, visit func(parse.Node)) {
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree != nil {
+			walkTemplateNode(tmpl.Tree.Root, visit)
+		}
+	}
+}
+
+func walkTemplateNode(node parse.Node, visit func(parse.Node)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walkTemplateNode(child, visit)
+		}
+		return
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+	}
+
+	visit(node)
+	switch n := node.(type) {
+	case *parse.ActionNode:
+		walkTemplateNode(n.Pipe, visit)
+	case *parse.IfNode:
+		walkTemplateNode(&n.BranchNode, visit)
+	case *parse.RangeNode:
+		walkTemplateNode(&n.BranchNode, visit)
+	case *parse.WithNode:
+		walkTemplateNode(&n.BranchNode, visit)
+	case *parse.BranchNode:
+		walkTemplateNode(n.Pipe, visit)
+		walkTemplateNode(n.List, visit)
+		walkTemplateNode(n.ElseList, visit)
+	case *parse.TemplateNode:
+		walkTemplateNode(n.Pipe, visit)
+	case *parse.PipeNode:
+		for _, cmd := range n.Cmds {
+			walkTemplateNode(cmd, visit)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			walkTemplateNode(arg, visit)
+		}
+	case *parse.ChainNode:
+		walkTemplateNode(n.Node, visit)
+	}
+}
+
+// defaultTemplateSampleData is the notification data templates are executed
+// against by the deep template validation, unless configured otherwise.
+var defaultTemplateSampleData = func() *amtemplate.Data {
+	startsAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	labels := amtemplate.KV{"alertname": "ExampleAlert", "severity": "critical", "job": "example", "instance": "example:9090"}
+	annotations := amtemplate.KV{"summary": "Example alert summary", "description": "Example alert description"}
+	return &amtemplate.Data{
+		Receiver: "example",
+		Status:   "firing",
+		Alerts: amtemplate.Alerts{
+			{
+				Status:       "firing",
+				Labels:       labels,
+				Annotations:  annotations,
+				StartsAt:     startsAt,
+				GeneratorURL: "http://prometheus.example/graph",
+				Fingerprint:  "0123456789abcdef",
+			},
+			{
+				Status:       "resolved",
+				Labels:       amtemplate.KV{"alertname": "ExampleAlert", "severity": "critical", "job": "example", "instance": "example:9091"},
+				Annotations:  annotations,
+				StartsAt:     startsAt,
+				EndsAt:       startsAt.Add(time.Hour),
+				GeneratorURL: "http://prometheus.example/graph",
+				Fingerprint:  "fedcba9876543210",
+			},
+		},
+		GroupLabels:       amtemplate.KV{"alertname": "ExampleAlert"},
+		CommonLabels:      amtemplate.KV{"alertname": "ExampleAlert", "severity": "critical", "job": "example"},
+		CommonAnnotations: annotations,
+		ExternalURL:       "http://alertmanager.example",
+	}
+}()
+
+// loadTemplateSampleData reads notification data from a JSON file, in the
+// format of the Alertmanager webhook payload.
+func loadTemplateSampleData(filename string) (*amtemplate.Data, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var data amtemplate.Data
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filename, err)
+	}
+	return &data, nil
+}
+
+// executeTemplateFiles executes the templates written inline in the
+// Alertmanager config of a config against the given notification data, to
+// catch the errors which only happen at runtime, including the ones of the
+// template file templates they call. As done by Alertmanager, template files
+// are parsed together as text templates, and each inline template is executed
+// on its own with the notification data as argument, so that nested templates
+// get the arguments they are actually called with.
+func executeTemplateFiles(c userconfig.Config, data *amtemplate.Data) error {
+	names := make([]string, 0, len(c.TemplateFiles))
+	for fn := range c.TemplateFiles {
+		names = append(names, fn)
+	}
+	sort.Strings(names)
+
+	tmpl := texttemplate.New("").Option("missingkey=zero").Funcs(texttemplate.FuncMap(amtemplate.DefaultFuncs))
+	for _, fn := range names {
+		if _, err := tmpl.New(fn).Parse(c.TemplateFiles[fn]); err != nil {
+			return err
+		}
+	}
+
+	texts, err := inlineTemplateTexts(c.AlertmanagerConfig)
+	if err != nil {
+		return err
+	}
+	for _, text := range texts {
+		t, err := tmpl.Clone()
+		if err != nil {
+			return err
+		}
+		if t, err = t.New("").Option("missingkey=zero").Parse(text); err != nil {
+			// Not a template, or one the Alertmanager would already reject.
+			continue
+		}
+		if err := t.Execute(io.Discard, data); err != nil {
+			return fmt.Errorf("executing template %q of the Alertmanager config: %w", text, err)
+		}
+	}
+	return nil
+}
+
+// inlineTemplateTexts returns the sorted string values of an Alertmanager
+// config which contain template actions.
+func inlineTemplateTexts(amCfg string) ([]string, error) {
+	if amCfg == "" {
+		return nil, nil
+	}
+	var raw interface{}
+	if err := yaml.Unmarshal([]byte(amCfg), &raw); err != nil {
+		return nil, err
+	}
+	seen := map[string]struct{}{}
+	var texts []string
+	for _, text := range yamlStrings(raw) {
+		if _, ok := seen[text]; ok || !strings.Contains(text, "{{") {
+			continue
+		}
+		seen[text] = struct{}{}
+		texts = append(texts, text)
+	}
+	sort.Strings(texts)
+	return texts, nil
+}
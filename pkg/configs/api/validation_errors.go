@@ -0,0 +1,121 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+
+	"github.com/cortexproject/cortex/pkg/configs/userconfig"
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+// ValidationErrorsView renders the problems found by the all-errors
+// validation: the first ones, up to the configured maximum, and how many
+// problems were found in total.
+// Exposed only for tests.
+type ValidationErrorsView struct {
+	Status      string   `json:"status"`
+	Errors      []string `json:"errors"`
+	TotalErrors int      `json:"total_errors"`
+}
+
+// validationErrors runs the same validation checks as validateConfig, but
+// reports every problem found rather than stopping at the first one. Problems
+// are reported per rules file, rule group, rule, template file and warning
+// where possible.
+func (a *API) validationErrors(cfg userconfig.Config, ex exemptions, deep bool) []error {
+	var errs []error
+	add := func(part string, check string, checkErrs ...error) {
+		if len(checkErrs) == 0 || ex.skip(check) {
+			return
+		}
+		for _, err := range checkErrs {
+			errs = append(errs, configValidationError{part: part, err: err})
+		}
+	}
+
+	if cfg.AlertmanagerConfig != "" {
+		if err := validateAlertmanagerConfig(cfg.AlertmanagerConfig, len(cfg.TemplateFiles), a.cfg, ex); err != nil {
+			errs = append(errs, configValidationError{part: "Alertmanager config", err: err})
+		}
+	}
+
+	if err := validateRulesConfig(cfg); err != nil {
+		// The other rules checks can't run on rules which don't parse.
+		add("rules", checkRules, ruleFileErrors(cfg, err)...)
+	} else {
+		violators, _ := ruleGroupNameViolations(cfg, a.ruleGroupNamePattern)
+		var nameErrs []error
+		for _, v := range violators {
+			nameErrs = append(nameErrs, fmt.Errorf("rule group name %s must match %q", v, a.ruleGroupNamePattern.String()))
+		}
+		add("rules", checkRuleGroupNames, nameErrs...)
+
+		violators, _ = ruleExpressionViolations(cfg, a.cfg.Validation.MaxRuleExpressionLength, a.cfg.Validation.MaxRuleExpressionNodes)
+		var exprErrs []error
+		for _, v := range violators {
+			exprErrs = append(exprErrs, errors.New(v))
+		}
+		add("rules", checkRuleExpressions, exprErrs...)
+	}
+
+	var templateErrs []error
+	for _, fn := range sortedKeys(cfg.TemplateFiles) {
+		if _, err := parseTemplateFile(fn, cfg.TemplateFiles[fn]); err != nil {
+			templateErrs = append(templateErrs, err)
+		}
+	}
+	if len(templateErrs) == 0 && deep {
+		if err := executeTemplateFiles(cfg, a.templateSampleData); err != nil {
+			templateErrs = append(templateErrs, err)
+		}
+	}
+	add("templates", checkTemplates, templateErrs...)
+
+	if a.isStrict(cfg.AlertmanagerConfig, &cfg.RulesConfig) {
+		for _, w := range a.configWarnings(cfg, ex) {
+			errs = append(errs, configValidationError{part: "Alertmanager config", err: strictValidationError([]string{w})})
+		}
+	}
+
+	return errs
+}
+
+// ruleFileErrors returns the errors of each of the rules files of a config
+// which failed to parse with err.
+func ruleFileErrors(cfg userconfig.Config, err error) []error {
+	if cfg.RulesConfig.FormatVersion != userconfig.RuleFormatV2 {
+		return []error{err}
+	}
+	resolved, refErr := cfg.RulesConfig.ResolveRefs()
+	if refErr != nil {
+		return []error{refErr}
+	}
+	var errs []error
+	for _, fn := range sortedKeys(resolved.Files) {
+		_, fileErrs := rulefmt.Parse([]byte(resolved.Files[fn]))
+		for _, fileErr := range fileErrs {
+			errs = append(errs, fmt.Errorf("error parsing %s: %v", fn, fileErr))
+		}
+	}
+	if len(errs) == 0 {
+		return []error{err}
+	}
+	return errs
+}
+
+// writeValidationErrors writes the first max problems found by the all-errors
+// validation, or all of them if max is 0.
+func writeValidationErrors(w http.ResponseWriter, errs []error, max int) {
+	view := ValidationErrorsView{Status: "error", Errors: []string{}, TotalErrors: len(errs)}
+	for _, err := range errs {
+		if max > 0 && len(view.Errors) == max {
+			break
+		}
+		view.Errors = append(view.Errors, err.Error())
+	}
+	w.WriteHeader(http.StatusBadRequest)
+	util.WriteJSONResponse(w, view)
+}
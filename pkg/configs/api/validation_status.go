@@ -0,0 +1,46 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/go-kit/log"
+
+	"github.com/cortexproject/cortex/pkg/configs/userconfig"
+)
+
+// ValidatedView renders a config along with its validation status.
+type ValidatedView struct {
+	userconfig.View `yaml:",inline"`
+	Validation      userconfig.ValidationStatus `json:"validation" yaml:"validation"`
+}
+
+// validationStatus validates a config, as done when it's posted.
+func (a *API) validationStatus(cfg userconfig.Config, ex exemptions) userconfig.ValidationStatus {
+	status := userconfig.ValidationStatus{Valid: true}
+	if err := a.validateConfig(cfg, ex); err != nil {
+		status.Valid = false
+		status.Error = err.Error()
+	}
+	status.Warnings = a.configWarnings(cfg, ex)
+	return status
+}
+
+func (a *API) configWarnings(cfg userconfig.Config, ex exemptions) []string {
+	if cfg.AlertmanagerConfig == "" {
+		return nil
+	}
+	return alertmanagerConfigWarnings(cfg.AlertmanagerConfig, &cfg.RulesConfig, a.ExternalLabels, ex)
+}
+
+// storedValidationStatus returns the validation status stored with a user's
+// config version when it was set, so that the exemptions and strictness of
+// that time apply. Versions stored without one, such as the ones not set
+// through the API, are validated now.
+func (a *API) storedValidationStatus(ctx context.Context, logger log.Logger, userID string, view userconfig.View) (userconfig.ValidationStatus, error) {
+	status, err := a.db.GetConfigValidationStatus(ctx, view.ID)
+	if err == sql.ErrNoRows {
+		return a.validationStatus(view.Config, a.exemptionsFor(logger, userID)), nil
+	}
+	return status, err
+}
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/json"
@@ -16,6 +17,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/common/version"
 	"github.com/weaveworks/common/instrument"
+	"github.com/weaveworks/common/user"
 
 	"github.com/cortexproject/cortex/pkg/configs/userconfig"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
@@ -56,6 +58,9 @@ type Client interface {
 
 	// GetAlerts fetches all the alerts that have changes since since.
 	GetAlerts(ctx context.Context, since userconfig.ID) (*ConfigsResponse, error)
+
+	// SetRulesEvaluationStatus reports the health of a user's rules.
+	SetRulesEvaluationStatus(ctx context.Context, userID string, status userconfig.RulesEvaluationStatus) error
 }
 
 // New creates a new ConfigClient.
@@ -130,16 +135,53 @@ func (c ConfigDBClient) GetAlerts(ctx context.Context, since userconfig.ID) (*Co
 	return response, err
 }
 
-func doRequest(endpoint string, timeout time.Duration, tlsConfig *tls.Config, since userconfig.ID) (*ConfigsResponse, error) {
-	req, err := http.NewRequest("GET", endpoint, nil)
+// SetRulesEvaluationStatus reports the health of a user's rules to the configs
+// server, replacing any status previously reported for them.
+func (c ConfigDBClient) SetRulesEvaluationStatus(ctx context.Context, userID string, status userconfig.RulesEvaluationStatus) error {
+	body, err := json.Marshal(status)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	endpoint := fmt.Sprintf("%s/private/api/prom/configs/rules/evaluation-status", c.URL.String())
+	return instrument.CollectedRequest(ctx, "SetRulesEvaluationStatus", configsRequestDuration, instrument.ErrorCode, func(ctx context.Context) error {
+		req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", fmt.Sprintf("Cortex/%s", version.Version))
+		if err := user.InjectOrgIDIntoHTTPRequest(user.InjectOrgID(ctx, userID), req); err != nil {
+			return err
+		}
+
+		resp, err := httpClient(c.Timeout, c.TLSConfig).Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
 
+		if resp.StatusCode != http.StatusNoContent {
+			return fmt.Errorf("Invalid response from configs server: %v", resp.StatusCode)
+		}
+		return nil
+	})
+}
+
+func httpClient(timeout time.Duration, tlsConfig *tls.Config) *http.Client {
 	client := &http.Client{Timeout: timeout}
 	if tlsConfig != nil {
 		client.Transport = &http.Transport{TLSClientConfig: tlsConfig}
 	}
+	return client
+}
+
+func doRequest(endpoint string, timeout time.Duration, tlsConfig *tls.Config, since userconfig.ID) (*ConfigsResponse, error) {
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client := httpClient(timeout, tlsConfig)
 
 	req.Header.Set("User-Agent", fmt.Sprintf("Cortex/%s", version.Version))
 
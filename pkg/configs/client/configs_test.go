@@ -1,8 +1,11 @@
 package client
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
@@ -53,3 +56,33 @@ func TestDoRequest(t *testing.T) {
 	}}
 	assert.Equal(t, &expected, resp)
 }
+
+func TestSetRulesEvaluationStatus(t *testing.T) {
+	status := userconfig.RulesEvaluationStatus{Groups: []userconfig.RuleGroupEvaluationStatus{{
+		Name:           "demo-service-alerts",
+		File:           "recording.rules",
+		LastEvaluation: time.Unix(1600000000, 0).UTC(),
+		Rules: []userconfig.RuleEvaluationStatus{{
+			Name:           "SomethingIsUp",
+			Health:         "err",
+			LastError:      "query timed out",
+			LastEvaluation: time.Unix(1600000000, 0).UTC(),
+		}},
+	}}}
+
+	var received userconfig.RulesEvaluationStatus
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/private/api/prom/configs/rules/evaluation-status", r.URL.Path)
+		assert.Equal(t, "user1", r.Header.Get("X-Scope-OrgID"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	c := ConfigDBClient{URL: u, Timeout: time.Second}
+	require.NoError(t, c.SetRulesEvaluationStatus(context.Background(), "user1", status))
+	assert.Equal(t, status, received)
+}
@@ -17,3 +17,8 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.DB.RegisterFlags(f)
 	cfg.API.RegisterFlags(f)
 }
+
+// Validate validates the config.
+func (cfg *Config) Validate() error {
+	return cfg.API.Validate()
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"time"
 
 	"github.com/cortexproject/cortex/pkg/configs/db/memory"
 	"github.com/cortexproject/cortex/pkg/configs/db/postgres"
@@ -52,6 +53,30 @@ type DB interface {
 	GetAllConfigs(ctx context.Context) (map[string]userconfig.View, error)
 	GetConfigs(ctx context.Context, since userconfig.ID) (map[string]userconfig.View, error)
 
+	// GetConfigsPage gets the configs of at most limit users, by ascending
+	// ID, among the configs with an ID higher than since or all configs if
+	// since is nil. Deleted configs are left out unless includeDeleted is
+	// set.
+	GetConfigsPage(ctx context.Context, since *userconfig.ID, limit int, includeDeleted bool) (map[string]userconfig.View, error)
+
+	// SetValidatedConfig sets a configuration along with the status of its
+	// validation, which is kept with the new version.
+	SetValidatedConfig(ctx context.Context, userID string, cfg userconfig.Config, status userconfig.ValidationStatus) error
+	// GetConfigValidationStatus gets the validation status kept with a config
+	// version, or sql.ErrNoRows if the version was set without one.
+	GetConfigValidationStatus(ctx context.Context, id userconfig.ID) (userconfig.ValidationStatus, error)
+
+	// GetConfigVersionCounts returns, for each user, how many versions of
+	// their config have been created at or after the provided time.
+	GetConfigVersionCounts(ctx context.Context, since time.Time) (map[string]int, error)
+
+	// GetRulesEvaluationStatus gets the health of the user's rule groups last
+	// reported by the rulers, sorted by file and group name.
+	GetRulesEvaluationStatus(ctx context.Context, userID string) (userconfig.RulesEvaluationStatus, error)
+	// SetRulesEvaluationStatus replaces the health of the given rule groups of
+	// the user, leaving the health of their other groups untouched.
+	SetRulesEvaluationStatus(ctx context.Context, userID string, status userconfig.RulesEvaluationStatus) error
+
 	DeactivateConfig(ctx context.Context, userID string) error
 	RestoreConfig(ctx context.Context, userID string) error
 
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/cortexproject/cortex/pkg/configs/userconfig"
@@ -11,15 +12,25 @@ import (
 
 // DB is an in-memory database for testing, and local development
 type DB struct {
-	cfgs map[string]userconfig.View
-	id   uint
+	cfgs        map[string]userconfig.View
+	versions    map[string][]time.Time
+	statuses    map[string]map[ruleGroupKey]userconfig.RuleGroupEvaluationStatus
+	validations map[userconfig.ID]userconfig.ValidationStatus
+	id          uint
+}
+
+type ruleGroupKey struct {
+	file, name string
 }
 
 // New creates a new in-memory database
 func New(_, _ string) (*DB, error) {
 	return &DB{
-		cfgs: map[string]userconfig.View{},
-		id:   0,
+		cfgs:        map[string]userconfig.View{},
+		versions:    map[string][]time.Time{},
+		statuses:    map[string]map[ruleGroupKey]userconfig.RuleGroupEvaluationStatus{},
+		validations: map[userconfig.ID]userconfig.ValidationStatus{},
+		id:          0,
 	}, nil
 }
 
@@ -38,10 +49,30 @@ func (d *DB) SetConfig(ctx context.Context, userID string, cfg userconfig.Config
 		return fmt.Errorf("invalid rule format version %v", cfg.RulesConfig.FormatVersion)
 	}
 	d.cfgs[userID] = userconfig.View{Config: cfg, ID: userconfig.ID(d.id)}
+	d.versions[userID] = append(d.versions[userID], time.Now())
 	d.id++
 	return nil
 }
 
+// SetValidatedConfig sets a configuration along with its validation status.
+func (d *DB) SetValidatedConfig(ctx context.Context, userID string, cfg userconfig.Config, status userconfig.ValidationStatus) error {
+	id := userconfig.ID(d.id)
+	if err := d.SetConfig(ctx, userID, cfg); err != nil {
+		return err
+	}
+	d.validations[id] = status
+	return nil
+}
+
+// GetConfigValidationStatus gets the validation status of a config version.
+func (d *DB) GetConfigValidationStatus(ctx context.Context, id userconfig.ID) (userconfig.ValidationStatus, error) {
+	status, ok := d.validations[id]
+	if !ok {
+		return userconfig.ValidationStatus{}, sql.ErrNoRows
+	}
+	return status, nil
+}
+
 // GetAllConfigs gets all of the userconfig.
 func (d *DB) GetAllConfigs(ctx context.Context) (map[string]userconfig.View, error) {
 	return d.cfgs, nil
@@ -58,6 +89,73 @@ func (d *DB) GetConfigs(ctx context.Context, since userconfig.ID) (map[string]us
 	return cfgs, nil
 }
 
+// GetConfigsPage gets a page of the configs, by ascending ID.
+func (d *DB) GetConfigsPage(ctx context.Context, since *userconfig.ID, limit int, includeDeleted bool) (map[string]userconfig.View, error) {
+	users := make([]string, 0, len(d.cfgs))
+	for user, c := range d.cfgs {
+		if (since == nil || c.ID > *since) && (includeDeleted || !c.IsDeleted()) {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return d.cfgs[users[i]].ID < d.cfgs[users[j]].ID })
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	cfgs := make(map[string]userconfig.View, len(users))
+	for _, user := range users {
+		cfgs[user] = d.cfgs[user]
+	}
+	return cfgs, nil
+}
+
+// GetConfigVersionCounts counts the config versions created since the given time.
+func (d *DB) GetConfigVersionCounts(ctx context.Context, since time.Time) (map[string]int, error) {
+	counts := map[string]int{}
+	for user, versions := range d.versions {
+		for _, createdAt := range versions {
+			if !createdAt.Before(since) {
+				counts[user]++
+			}
+		}
+	}
+	return counts, nil
+}
+
+// GetRulesEvaluationStatus gets the health of a user's rule groups.
+func (d *DB) GetRulesEvaluationStatus(ctx context.Context, userID string) (userconfig.RulesEvaluationStatus, error) {
+	groups, ok := d.statuses[userID]
+	if !ok {
+		return userconfig.RulesEvaluationStatus{}, sql.ErrNoRows
+	}
+	status := userconfig.RulesEvaluationStatus{Groups: make([]userconfig.RuleGroupEvaluationStatus, 0, len(groups))}
+	for _, group := range groups {
+		status.Groups = append(status.Groups, group)
+	}
+	sort.Slice(status.Groups, func(i, j int) bool {
+		if status.Groups[i].File != status.Groups[j].File {
+			return status.Groups[i].File < status.Groups[j].File
+		}
+		return status.Groups[i].Name < status.Groups[j].Name
+	})
+	return status, nil
+}
+
+// SetRulesEvaluationStatus replaces the health of some of a user's rule groups.
+func (d *DB) SetRulesEvaluationStatus(ctx context.Context, userID string, status userconfig.RulesEvaluationStatus) error {
+	if len(status.Groups) == 0 {
+		return nil
+	}
+	groups, ok := d.statuses[userID]
+	if !ok {
+		groups = map[ruleGroupKey]userconfig.RuleGroupEvaluationStatus{}
+		d.statuses[userID] = groups
+	}
+	for _, group := range status.Groups {
+		groups[ruleGroupKey{file: group.File, name: group.Name}] = group
+	}
+	return nil
+}
+
 // SetDeletedAtConfig sets a deletedAt for configuration
 // by adding a single new row with deleted_at set
 // the same as SetConfig is actually insert
@@ -69,6 +167,7 @@ func (d *DB) SetDeletedAtConfig(ctx context.Context, userID string, deletedAt ti
 	cv.DeletedAt = deletedAt
 	cv.ID = userconfig.ID(d.id)
 	d.cfgs[userID] = cv
+	d.versions[userID] = append(d.versions[userID], time.Now())
 	d.id++
 	return nil
 }
@@ -115,6 +115,10 @@ func (d DB) findConfigs(filter squirrel.Sqlizer) (map[string]userconfig.View, er
 	if err != nil {
 		return nil, err
 	}
+	return readConfigs(rows)
+}
+
+func readConfigs(rows *sql.Rows) (map[string]userconfig.View, error) {
 	defer rows.Close()
 	cfgs := map[string]userconfig.View{}
 	for rows.Next() {
@@ -122,7 +126,7 @@ func (d DB) findConfigs(filter squirrel.Sqlizer) (map[string]userconfig.View, er
 		var cfgBytes []byte
 		var userID string
 		var deletedAt pq.NullTime
-		err = rows.Scan(&cfg.ID, &userID, &cfgBytes, &deletedAt)
+		err := rows.Scan(&cfg.ID, &userID, &cfgBytes, &deletedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -135,7 +139,7 @@ func (d DB) findConfigs(filter squirrel.Sqlizer) (map[string]userconfig.View, er
 	}
 
 	// Check for any errors encountered.
-	err = rows.Err()
+	err := rows.Err()
 	if err != nil {
 		return nil, err
 	}
@@ -164,6 +168,15 @@ func (d DB) GetConfig(ctx context.Context, userID string) (userconfig.View, erro
 
 // SetConfig sets a configuration.
 func (d DB) SetConfig(ctx context.Context, userID string, cfg userconfig.Config) error {
+	return d.insertConfig(userID, cfg, nil)
+}
+
+// SetValidatedConfig sets a configuration along with its validation status.
+func (d DB) SetValidatedConfig(ctx context.Context, userID string, cfg userconfig.Config, status userconfig.ValidationStatus) error {
+	return d.insertConfig(userID, cfg, &status)
+}
+
+func (d DB) insertConfig(userID string, cfg userconfig.Config, status *userconfig.ValidationStatus) error {
 	if !cfg.RulesConfig.FormatVersion.IsValid() {
 		return fmt.Errorf("invalid rule format version %v", cfg.RulesConfig.FormatVersion)
 	}
@@ -172,13 +185,38 @@ func (d DB) SetConfig(ctx context.Context, userID string, cfg userconfig.Config)
 		return err
 	}
 
+	columns := []string{"owner_id", "owner_type", "subsystem", "config"}
+	values := []interface{}{userID, entityType, subsystem, cfgBytes}
+	if status != nil {
+		statusBytes, err := json.Marshal(status)
+		if err != nil {
+			return err
+		}
+		columns = append(columns, "validation")
+		values = append(values, statusBytes)
+	}
 	_, err = d.Insert("configs").
-		Columns("owner_id", "owner_type", "subsystem", "config").
-		Values(userID, entityType, subsystem, cfgBytes).
+		Columns(columns...).
+		Values(values...).
 		Exec()
 	return err
 }
 
+// GetConfigValidationStatus gets the validation status of a config version.
+func (d DB) GetConfigValidationStatus(ctx context.Context, id userconfig.ID) (userconfig.ValidationStatus, error) {
+	var status userconfig.ValidationStatus
+	var statusBytes []byte
+	err := d.Select("validation").
+		From("configs").
+		Where(squirrel.And{allConfigs, squirrel.Eq{"id": id}, squirrel.NotEq{"validation": nil}}).
+		QueryRow().Scan(&statusBytes)
+	if err != nil {
+		return status, err
+	}
+	err = json.Unmarshal(statusBytes, &status)
+	return status, err
+}
+
 // GetAllConfigs gets all of the userconfig.
 func (d DB) GetAllConfigs(ctx context.Context) (map[string]userconfig.View, error) {
 	return d.findConfigs(allConfigs)
@@ -192,6 +230,34 @@ func (d DB) GetConfigs(ctx context.Context, since userconfig.ID) (map[string]use
 	})
 }
 
+// GetConfigsPage gets a page of the configs, by ascending ID. The latest
+// config of every user is picked before the page is ordered and limited.
+func (d DB) GetConfigsPage(ctx context.Context, since *userconfig.ID, limit int, includeDeleted bool) (map[string]userconfig.View, error) {
+	filter := squirrel.And{allConfigs}
+	if since != nil {
+		filter = append(filter, squirrel.Gt{"id": *since})
+	}
+	// The subquery uses the default placeholders, which the outer query
+	// renumbers.
+	latest := squirrel.Select("id", "owner_id", "config", "deleted_at").
+		Options("DISTINCT ON (owner_id)").
+		From("configs").
+		Where(filter).
+		OrderBy("owner_id, id DESC")
+	query := d.Select("id", "owner_id", "config", "deleted_at").
+		FromSelect(latest, "latest").
+		OrderBy("id").
+		Limit(uint64(limit))
+	if !includeDeleted {
+		query = query.Where(squirrel.Eq{"deleted_at": nil})
+	}
+	rows, err := query.Query()
+	if err != nil {
+		return nil, err
+	}
+	return readConfigs(rows)
+}
+
 // GetRulesConfig gets the latest alertmanager config for a user.
 func (d DB) GetRulesConfig(ctx context.Context, userID string) (userconfig.VersionedRulesConfig, error) {
 	current, err := d.GetConfig(ctx, userID)
@@ -301,6 +367,94 @@ func (d DB) GetRulesConfigs(ctx context.Context, since userconfig.ID) (map[strin
 	})
 }
 
+// GetConfigVersionCounts counts the config versions created since the given time.
+func (d DB) GetConfigVersionCounts(ctx context.Context, since time.Time) (map[string]int, error) {
+	rows, err := d.Select("owner_id", "count(*)").
+		From("configs").
+		Where(squirrel.And{
+			allConfigs,
+			squirrel.GtOrEq{"created_at": since},
+		}).
+		GroupBy("owner_id").
+		Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := map[string]int{}
+	for rows.Next() {
+		var userID string
+		var count int
+		if err := rows.Scan(&userID, &count); err != nil {
+			return nil, err
+		}
+		counts[userID] = count
+	}
+	return counts, rows.Err()
+}
+
+// GetRulesEvaluationStatus gets the health of a user's rule groups.
+func (d DB) GetRulesEvaluationStatus(ctx context.Context, userID string) (userconfig.RulesEvaluationStatus, error) {
+	var status userconfig.RulesEvaluationStatus
+	rows, err := d.Select("status").
+		From("rules_evaluation_status").
+		Where(squirrel.Eq{"owner_id": userID}).
+		OrderBy("file", "group_name").
+		Query()
+	if err != nil {
+		return status, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var statusBytes []byte
+		if err := rows.Scan(&statusBytes); err != nil {
+			return status, err
+		}
+		var group userconfig.RuleGroupEvaluationStatus
+		if err := json.Unmarshal(statusBytes, &group); err != nil {
+			return status, err
+		}
+		status.Groups = append(status.Groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		return status, err
+	}
+	if len(status.Groups) == 0 {
+		return status, sql.ErrNoRows
+	}
+	return status, nil
+}
+
+// SetRulesEvaluationStatus replaces the health of some of a user's rule
+// groups, in a single statement.
+func (d DB) SetRulesEvaluationStatus(ctx context.Context, userID string, status userconfig.RulesEvaluationStatus) error {
+	if len(status.Groups) == 0 {
+		return nil
+	}
+	// A statement can't upsert the same row twice: the last status of a group
+	// wins, as when setting them one by one.
+	last := make(map[[2]string]int, len(status.Groups))
+	for i, group := range status.Groups {
+		last[[2]string{group.File, group.Name}] = i
+	}
+	insert := d.Insert("rules_evaluation_status").
+		Columns("owner_id", "file", "group_name", "status")
+	for i, group := range status.Groups {
+		if last[[2]string{group.File, group.Name}] != i {
+			continue
+		}
+		groupBytes, err := json.Marshal(group)
+		if err != nil {
+			return err
+		}
+		insert = insert.Values(userID, group.File, group.Name, groupBytes)
+	}
+	_, err := insert.
+		Suffix("ON CONFLICT (owner_id, file, group_name) DO UPDATE SET status = EXCLUDED.status, updated_at = now()").
+		Exec()
+	return err
+}
+
 // SetDeletedAtConfig sets a deletedAt for configuration
 // by adding a single new row with deleted_at set
 // the same as SetConfig is actually insert
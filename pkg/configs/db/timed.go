@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/weaveworks/common/instrument"
@@ -65,6 +66,62 @@ func (t timed) GetConfigs(ctx context.Context, since userconfig.ID) (map[string]
 	return cfgs, err
 }
 
+func (t timed) GetConfigsPage(ctx context.Context, since *userconfig.ID, limit int, includeDeleted bool) (map[string]userconfig.View, error) {
+	var cfgs map[string]userconfig.View
+	err := instrument.CollectedRequest(ctx, "DB.GetConfigsPage", databaseRequestDuration, instrument.ErrorCode, func(ctx context.Context) error {
+		var err error
+		cfgs, err = t.d.GetConfigsPage(ctx, since, limit, includeDeleted)
+		return err
+	})
+
+	return cfgs, err
+}
+
+func (t timed) SetValidatedConfig(ctx context.Context, userID string, cfg userconfig.Config, status userconfig.ValidationStatus) error {
+	return instrument.CollectedRequest(ctx, "DB.SetValidatedConfig", databaseRequestDuration, instrument.ErrorCode, func(ctx context.Context) error {
+		return t.d.SetValidatedConfig(ctx, userID, cfg, status)
+	})
+}
+
+func (t timed) GetConfigValidationStatus(ctx context.Context, id userconfig.ID) (userconfig.ValidationStatus, error) {
+	var status userconfig.ValidationStatus
+	err := instrument.CollectedRequest(ctx, "DB.GetConfigValidationStatus", databaseRequestDuration, instrument.ErrorCode, func(ctx context.Context) error {
+		var err error
+		status, err = t.d.GetConfigValidationStatus(ctx, id)
+		return err
+	})
+
+	return status, err
+}
+
+func (t timed) GetConfigVersionCounts(ctx context.Context, since time.Time) (map[string]int, error) {
+	var counts map[string]int
+	err := instrument.CollectedRequest(ctx, "DB.GetConfigVersionCounts", databaseRequestDuration, instrument.ErrorCode, func(ctx context.Context) error {
+		var err error
+		counts, err = t.d.GetConfigVersionCounts(ctx, since)
+		return err
+	})
+
+	return counts, err
+}
+
+func (t timed) GetRulesEvaluationStatus(ctx context.Context, userID string) (userconfig.RulesEvaluationStatus, error) {
+	var status userconfig.RulesEvaluationStatus
+	err := instrument.CollectedRequest(ctx, "DB.GetRulesEvaluationStatus", databaseRequestDuration, instrument.ErrorCode, func(ctx context.Context) error {
+		var err error
+		status, err = t.d.GetRulesEvaluationStatus(ctx, userID)
+		return err
+	})
+
+	return status, err
+}
+
+func (t timed) SetRulesEvaluationStatus(ctx context.Context, userID string, status userconfig.RulesEvaluationStatus) error {
+	return instrument.CollectedRequest(ctx, "DB.SetRulesEvaluationStatus", databaseRequestDuration, instrument.ErrorCode, func(ctx context.Context) error {
+		return t.d.SetRulesEvaluationStatus(ctx, userID, status)
+	})
+}
+
 func (t timed) DeactivateConfig(ctx context.Context, userID string) error {
 	return instrument.CollectedRequest(ctx, "DB.DeactivateConfig", databaseRequestDuration, instrument.ErrorCode, func(ctx context.Context) error {
 		return t.d.DeactivateConfig(ctx, userID)
@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/cortexproject/cortex/pkg/configs/userconfig"
 	util_log "github.com/cortexproject/cortex/pkg/util/log"
@@ -39,6 +40,36 @@ func (t traced) GetConfigs(ctx context.Context, since userconfig.ID) (cfgs map[s
 	return t.d.GetConfigs(ctx, since)
 }
 
+func (t traced) GetConfigsPage(ctx context.Context, since *userconfig.ID, limit int, includeDeleted bool) (cfgs map[string]userconfig.View, err error) {
+	defer func() { t.trace("GetConfigsPage", since, limit, includeDeleted, cfgs, err) }()
+	return t.d.GetConfigsPage(ctx, since, limit, includeDeleted)
+}
+
+func (t traced) SetValidatedConfig(ctx context.Context, userID string, cfg userconfig.Config, status userconfig.ValidationStatus) (err error) {
+	defer func() { t.trace("SetValidatedConfig", userID, cfg, status, err) }()
+	return t.d.SetValidatedConfig(ctx, userID, cfg, status)
+}
+
+func (t traced) GetConfigValidationStatus(ctx context.Context, id userconfig.ID) (status userconfig.ValidationStatus, err error) {
+	defer func() { t.trace("GetConfigValidationStatus", id, status, err) }()
+	return t.d.GetConfigValidationStatus(ctx, id)
+}
+
+func (t traced) GetConfigVersionCounts(ctx context.Context, since time.Time) (counts map[string]int, err error) {
+	defer func() { t.trace("GetConfigVersionCounts", since, counts, err) }()
+	return t.d.GetConfigVersionCounts(ctx, since)
+}
+
+func (t traced) GetRulesEvaluationStatus(ctx context.Context, userID string) (status userconfig.RulesEvaluationStatus, err error) {
+	defer func() { t.trace("GetRulesEvaluationStatus", userID, status, err) }()
+	return t.d.GetRulesEvaluationStatus(ctx, userID)
+}
+
+func (t traced) SetRulesEvaluationStatus(ctx context.Context, userID string, status userconfig.RulesEvaluationStatus) (err error) {
+	defer func() { t.trace("SetRulesEvaluationStatus", userID, status, err) }()
+	return t.d.SetRulesEvaluationStatus(ctx, userID, status)
+}
+
 func (t traced) DeactivateConfig(ctx context.Context, userID string) (err error) {
 	defer func() { t.trace("DeactivateConfig", userID, err) }()
 	return t.d.DeactivateConfig(ctx, userID)
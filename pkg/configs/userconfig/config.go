@@ -11,7 +11,6 @@ import (
 	"github.com/prometheus/prometheus/model/rulefmt"
 	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/prometheus/prometheus/rules"
-	"gopkg.in/yaml.v3"
 
 	util_log "github.com/cortexproject/cortex/pkg/util/log"
 )
@@ -56,9 +55,9 @@ func (v RuleFormatVersion) MarshalJSON() ([]byte, error) {
 func (v RuleFormatVersion) MarshalYAML() (interface{}, error) {
 	switch v {
 	case RuleFormatV1:
-		return yaml.Marshal("1")
+		return "1", nil
 	case RuleFormatV2:
-		return yaml.Marshal("2")
+		return "2", nil
 	default:
 		return nil, fmt.Errorf("unknown rule format version %d", v)
 	}
@@ -110,10 +109,10 @@ type Config struct {
 // saved in the config DB that didn't have a rule format version yet and
 // just had a top-level field for the rule files.
 type configCompat struct {
-	RulesFiles         map[string]string `json:"rules_files" yaml:"rules_files"`
+	RulesFiles         map[string]string `json:"rules_files" yaml:"rules_files,omitempty"`
 	RuleFormatVersion  RuleFormatVersion `json:"rule_format_version" yaml:"rule_format_version"`
-	TemplateFiles      map[string]string `json:"template_files" yaml:"template_files"`
-	AlertmanagerConfig string            `json:"alertmanager_config" yaml:"alertmanager_config"`
+	TemplateFiles      map[string]string `json:"template_files" yaml:"template_files,omitempty"`
+	AlertmanagerConfig string            `json:"alertmanager_config" yaml:"alertmanager_config,omitempty"`
 }
 
 // MarshalJSON implements json.Marshaler.
@@ -137,7 +136,7 @@ func (c Config) MarshalYAML() (interface{}, error) {
 		AlertmanagerConfig: c.AlertmanagerConfig,
 	}
 
-	return yaml.Marshal(compat)
+	return compat, nil
 }
 
 // UnmarshalJSON implements json.Unmarshaler.
@@ -181,9 +180,9 @@ func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 // _version_ of a configuration a unique ID and guarantees that later versions
 // have greater IDs.
 type View struct {
-	ID        ID        `json:"id"`
-	Config    Config    `json:"config"`
-	DeletedAt time.Time `json:"deleted_at"`
+	ID        ID        `json:"id" yaml:"id"`
+	Config    Config    `json:"config" yaml:"config"`
+	DeletedAt time.Time `json:"deleted_at" yaml:"deleted_at"`
 }
 
 // IsDeleted tells you if the config is deleted.
@@ -257,6 +256,10 @@ func (c RulesConfig) ParseFormatted() (map[string]rulefmt.RuleGroups, error) {
 // parseV2 parses and validates the content of the rule files in a RulesConfig
 // according to the Prometheus 2.x rule format.
 func (c RulesConfig) parseV2Formatted() (map[string]rulefmt.RuleGroups, error) {
+	c, err := c.resolveRefsForEvaluation()
+	if err != nil {
+		return nil, err
+	}
 	ruleMap := map[string]rulefmt.RuleGroups{}
 
 	for fn, content := range c.Files {
@@ -284,6 +287,10 @@ func (c RulesConfig) parseV2Formatted() (map[string]rulefmt.RuleGroups, error) {
 // once, not for every evaluation (or risk losing alert pending states). So
 // it's probably better to just return a set of rules.Rule here.
 func (c RulesConfig) parseV2() (map[string][]rules.Rule, error) {
+	c, err := c.resolveRefsForEvaluation()
+	if err != nil {
+		return nil, err
+	}
 	groups := map[string][]rules.Rule{}
 
 	for fn, content := range c.Files {
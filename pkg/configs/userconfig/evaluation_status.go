@@ -0,0 +1,29 @@
+package userconfig
+
+import (
+	"time"
+)
+
+// RulesEvaluationStatus is the health of the rules of a user, as reported by
+// the ruler after evaluating them.
+type RulesEvaluationStatus struct {
+	Groups []RuleGroupEvaluationStatus `json:"groups"`
+}
+
+// RuleGroupEvaluationStatus is the health of the rules of a rule group.
+type RuleGroupEvaluationStatus struct {
+	Name           string                 `json:"name"`
+	File           string                 `json:"file"`
+	LastEvaluation time.Time              `json:"last_evaluation"`
+	Rules          []RuleEvaluationStatus `json:"rules"`
+}
+
+// RuleEvaluationStatus is the health of a rule after its last evaluation. The
+// health is one of the Prometheus rule health values: "ok", "err" or
+// "unknown".
+type RuleEvaluationStatus struct {
+	Name           string    `json:"name"`
+	Health         string    `json:"health"`
+	LastError      string    `json:"last_error,omitempty"`
+	LastEvaluation time.Time `json:"last_evaluation"`
+}
@@ -0,0 +1,254 @@
+package userconfig
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RuleGroupRefKey is the key of a rule group entry that, instead of defining
+// a group inline, references groups defined in another rules file of the same
+// config. The value is either `<file>`, to inline all the groups of that file,
+// or `<file>#<group>`, to inline a single group:
+//
+//	groups:
+//	- $ref: common.yml#instance-alerts
+//	- name: local
+//	  rules: [...]
+//
+// Each rule group is evaluated once: a group pulled in by references is only
+// evaluated as part of the first file referencing it, in lexical order, and not
+// as part of the file defining it. Files left without any group, such as files
+// only holding groups to reference, aren't evaluated at all.
+const RuleGroupRefKey = "$ref"
+
+// ResolveRefs returns a copy of the rules config in which every rule group
+// reference has been replaced by the groups it refers to. Files without any
+// reference are returned verbatim. An error is returned if a reference cannot
+// be resolved or if references form a cycle.
+func (c RulesConfig) ResolveRefs() (RulesConfig, error) {
+	return c.resolveRefs(false)
+}
+
+// resolveRefsForEvaluation is like ResolveRefs, except that every group is
+// kept in the single file it is evaluated as part of, as documented by
+// RuleGroupRefKey. Files left without any group are dropped.
+func (c RulesConfig) resolveRefsForEvaluation() (RulesConfig, error) {
+	return c.resolveRefs(true)
+}
+
+func (c RulesConfig) resolveRefs(dedupe bool) (RulesConfig, error) {
+	if c.FormatVersion != RuleFormatV2 || len(c.Files) == 0 {
+		return c, nil
+	}
+
+	r := refResolver{
+		files:    c.Files,
+		resolved: map[string][]*yaml.Node{},
+		visiting: map[string]bool{},
+		origins:  map[*yaml.Node]string{},
+	}
+	fns := make([]string, 0, len(c.Files))
+	for fn := range c.Files {
+		fns = append(fns, fn)
+	}
+	sort.Strings(fns)
+
+	// Groups of the files to render, by file name.
+	rendered := map[string][]*yaml.Node{}
+	for _, fn := range fns {
+		if _, _, hasRefs := parseRuleGroupNodes(c.Files[fn]); !hasRefs {
+			// Leave files without references (or that aren't even valid YAML)
+			// untouched, so that any error is reported by the rules parser.
+			continue
+		}
+		resolved, err := r.resolve(fn, nil)
+		if err != nil {
+			return RulesConfig{}, err
+		}
+		rendered[fn] = resolved
+	}
+
+	if dedupe {
+		// inliners maps every group pulled in by references to the first file
+		// pulling it in, which is the only one to keep it.
+		inliners := map[*yaml.Node]string{}
+		for _, fn := range fns {
+			for _, group := range rendered[fn] {
+				if _, ok := inliners[group]; !ok && r.origins[group] != fn {
+					inliners[group] = fn
+				}
+			}
+		}
+		// r.resolved holds the referencing files as well as the referenced ones.
+		for fn, groups := range r.resolved {
+			kept := make([]*yaml.Node, 0, len(groups))
+			seen := map[*yaml.Node]bool{}
+			for _, group := range groups {
+				inliner, inlined := inliners[group]
+				if seen[group] || (inlined && inliner != fn) || (!inlined && r.origins[group] != fn) {
+					continue
+				}
+				seen[group] = true
+				kept = append(kept, group)
+			}
+			if _, ok := rendered[fn]; ok || len(kept) != len(groups) {
+				rendered[fn] = kept
+			}
+		}
+	}
+
+	files := make(map[string]string, len(c.Files))
+	for _, fn := range fns {
+		groups, ok := rendered[fn]
+		if !ok {
+			files[fn] = c.Files[fn]
+			continue
+		}
+		if dedupe && len(groups) == 0 {
+			continue
+		}
+		doc, groupsNode, _ := parseRuleGroupNodes(c.Files[fn])
+		groupsNode.Content = groups
+		var out strings.Builder
+		enc := yaml.NewEncoder(&out)
+		enc.SetIndent(2)
+		if err := enc.Encode(doc); err != nil {
+			return RulesConfig{}, fmt.Errorf("error rendering %s: %v", fn, err)
+		}
+		files[fn] = out.String()
+	}
+
+	return RulesConfig{FormatVersion: c.FormatVersion, Files: files}, nil
+}
+
+type refResolver struct {
+	files    map[string]string
+	resolved map[string][]*yaml.Node
+	visiting map[string]bool
+	// origins maps every group defined inline to the file defining it.
+	origins map[*yaml.Node]string
+}
+
+// resolve returns the fully resolved group nodes of the given file. path is
+// the chain of files that led to this one, used to report cycles.
+func (r *refResolver) resolve(fn string, path []string) ([]*yaml.Node, error) {
+	if groups, ok := r.resolved[fn]; ok {
+		return groups, nil
+	}
+	path = append(path, fn)
+	if r.visiting[fn] {
+		return nil, fmt.Errorf("cyclic %s: %s", RuleGroupRefKey, strings.Join(path, " -> "))
+	}
+	r.visiting[fn] = true
+	defer delete(r.visiting, fn)
+
+	_, groups, _ := parseRuleGroupNodes(r.files[fn])
+	if groups == nil {
+		return nil, fmt.Errorf("error parsing %s: no rule groups found", fn)
+	}
+
+	var resolved []*yaml.Node
+	for _, group := range groups.Content {
+		ref, ok, err := ruleGroupRef(group)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s in %s: %v", RuleGroupRefKey, fn, err)
+		}
+		if !ok {
+			r.origins[group] = fn
+			resolved = append(resolved, group)
+			continue
+		}
+
+		target, groupName := ref, ""
+		if i := strings.Index(ref, "#"); i >= 0 {
+			target, groupName = ref[:i], ref[i+1:]
+		}
+		if _, exists := r.files[target]; !exists {
+			return nil, fmt.Errorf("unresolved %s %q in %s: no such rules file", RuleGroupRefKey, ref, fn)
+		}
+		targetGroups, err := r.resolve(target, path)
+		if err != nil {
+			return nil, err
+		}
+		if groupName == "" {
+			resolved = append(resolved, targetGroups...)
+			continue
+		}
+		found := false
+		for _, g := range targetGroups {
+			if ruleGroupName(g) == groupName {
+				resolved = append(resolved, g)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unresolved %s %q in %s: no such rule group", RuleGroupRefKey, ref, fn)
+		}
+	}
+
+	r.resolved[fn] = resolved
+	return resolved, nil
+}
+
+// parseRuleGroupNodes decodes a rules file and returns its document node,
+// the sequence node holding its groups and whether any group is a reference.
+// The groups node is nil if the file can't be decoded.
+func parseRuleGroupNodes(content string) (*yaml.Node, *yaml.Node, bool) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil || len(doc.Content) == 0 {
+		return nil, nil, false
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, nil, false
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "groups" || root.Content[i+1].Kind != yaml.SequenceNode {
+			continue
+		}
+		groups := root.Content[i+1]
+		for _, group := range groups.Content {
+			if _, ok, _ := ruleGroupRef(group); ok {
+				return &doc, groups, true
+			}
+		}
+		return &doc, groups, false
+	}
+	return nil, nil, false
+}
+
+// ruleGroupRef returns the reference held by a rule group node, if any.
+func ruleGroupRef(group *yaml.Node) (string, bool, error) {
+	if group.Kind != yaml.MappingNode {
+		return "", false, nil
+	}
+	for i := 0; i+1 < len(group.Content); i += 2 {
+		if group.Content[i].Value != RuleGroupRefKey {
+			continue
+		}
+		if len(group.Content) != 2 {
+			return "", true, fmt.Errorf("%s must be the only key of a rule group entry", RuleGroupRefKey)
+		}
+		if group.Content[i+1].Kind != yaml.ScalarNode || group.Content[i+1].Value == "" {
+			return "", true, fmt.Errorf("%s must be a non-empty string", RuleGroupRefKey)
+		}
+		return group.Content[i+1].Value, true, nil
+	}
+	return "", false, nil
+}
+
+func ruleGroupName(group *yaml.Node) string {
+	if group.Kind != yaml.MappingNode {
+		return ""
+	}
+	for i := 0; i+1 < len(group.Content); i += 2 {
+		if group.Content[i].Value == "name" {
+			return group.Content[i+1].Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,185 @@
+package userconfig
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sharedRulesFile = `groups:
+- name: shared-a
+  rules:
+  - record: a
+    expr: up
+- name: shared-b
+  rules:
+  - record: b
+    expr: up
+`
+
+func TestResolveRefs(t *testing.T) {
+	for name, tc := range map[string]struct {
+		files    map[string]string
+		expected map[string][]string
+		// evaluated are the groups parsed for evaluation, when they differ
+		// from the expected resolved groups.
+		evaluated   map[string][]string
+		errContains string
+	}{
+		"no refs": {
+			files: map[string]string{"shared.yml": sharedRulesFile},
+			expected: map[string][]string{
+				"shared.yml": {"shared-a", "shared-b"},
+			},
+		},
+		"whole file ref": {
+			files: map[string]string{
+				"shared.yml": sharedRulesFile,
+				"main.yml":   "groups:\n- $ref: shared.yml\n",
+			},
+			expected: map[string][]string{
+				"shared.yml": {"shared-a", "shared-b"},
+				"main.yml":   {"shared-a", "shared-b"},
+			},
+			evaluated: map[string][]string{
+				"main.yml": {"shared-a", "shared-b"},
+			},
+		},
+		"single group ref next to inline group": {
+			files: map[string]string{
+				"shared.yml": sharedRulesFile,
+				"main.yml":   "groups:\n- $ref: shared.yml#shared-b\n- name: local\n  rules:\n  - record: c\n    expr: up\n",
+			},
+			expected: map[string][]string{
+				"shared.yml": {"shared-a", "shared-b"},
+				"main.yml":   {"shared-b", "local"},
+			},
+			evaluated: map[string][]string{
+				"shared.yml": {"shared-a"},
+				"main.yml":   {"shared-b", "local"},
+			},
+		},
+		"group referenced by several files": {
+			files: map[string]string{
+				"shared.yml": sharedRulesFile,
+				"b.yml":      "groups:\n- $ref: shared.yml#shared-a\n",
+				"a.yml":      "groups:\n- $ref: shared.yml\n- name: local\n  rules:\n  - record: c\n    expr: up\n",
+			},
+			expected: map[string][]string{
+				"shared.yml": {"shared-a", "shared-b"},
+				"b.yml":      {"shared-a"},
+				"a.yml":      {"shared-a", "shared-b", "local"},
+			},
+			evaluated: map[string][]string{
+				"a.yml": {"shared-a", "shared-b", "local"},
+			},
+		},
+		"transitive ref": {
+			files: map[string]string{
+				"shared.yml": sharedRulesFile,
+				"middle.yml": "groups:\n- $ref: shared.yml#shared-a\n",
+				"main.yml":   "groups:\n- $ref: middle.yml\n",
+			},
+			expected: map[string][]string{
+				"shared.yml": {"shared-a", "shared-b"},
+				"middle.yml": {"shared-a"},
+				"main.yml":   {"shared-a"},
+			},
+			evaluated: map[string][]string{
+				"shared.yml": {"shared-b"},
+				"main.yml":   {"shared-a"},
+			},
+		},
+		"missing file": {
+			files:       map[string]string{"main.yml": "groups:\n- $ref: missing.yml\n"},
+			errContains: `unresolved $ref "missing.yml" in main.yml: no such rules file`,
+		},
+		"missing group": {
+			files: map[string]string{
+				"shared.yml": sharedRulesFile,
+				"main.yml":   "groups:\n- $ref: shared.yml#missing\n",
+			},
+			errContains: `unresolved $ref "shared.yml#missing" in main.yml: no such rule group`,
+		},
+		"cycle": {
+			files: map[string]string{
+				"a.yml": "groups:\n- $ref: b.yml\n",
+				"b.yml": "groups:\n- $ref: a.yml\n",
+			},
+			errContains: "cyclic $ref",
+		},
+		"self reference": {
+			files:       map[string]string{"a.yml": "groups:\n- name: x\n  rules: []\n- $ref: a.yml#x\n"},
+			errContains: "cyclic $ref: a.yml -> a.yml",
+		},
+		"ref with other keys": {
+			files: map[string]string{
+				"shared.yml": sharedRulesFile,
+				"main.yml":   "groups:\n- $ref: shared.yml\n  name: other\n",
+			},
+			errContains: "$ref must be the only key of a rule group entry",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := RulesConfig{FormatVersion: RuleFormatV2, Files: tc.files}
+			resolved, err := cfg.ResolveRefs()
+			if tc.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.errContains)
+
+				_, err = cfg.ParseFormatted()
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.files["shared.yml"], resolved.Files["shared.yml"], "files without refs must be kept verbatim")
+
+			actual := map[string][]string{}
+			for fn, content := range resolved.Files {
+				rgs, errs := rulefmt.Parse([]byte(content))
+				require.Empty(t, errs)
+				for _, rg := range rgs.Groups {
+					actual[fn] = append(actual[fn], rg.Name)
+				}
+			}
+			assert.Equal(t, tc.expected, actual)
+
+			parsed, err := cfg.ParseFormatted()
+			require.NoError(t, err)
+			evaluated := map[string][]string{}
+			records := map[string]int{}
+			for fn, rgs := range parsed {
+				for _, rg := range rgs.Groups {
+					evaluated[fn] = append(evaluated[fn], rg.Name)
+					for _, rl := range rg.Rules {
+						records[rl.Record.Value]++
+					}
+				}
+			}
+			if tc.evaluated == nil {
+				tc.evaluated = tc.expected
+			}
+			assert.Equal(t, tc.evaluated, evaluated)
+			for record, count := range records {
+				assert.Equal(t, 1, count, "rule %q is evaluated %d times", record, count)
+			}
+
+			// The rules parsed for evaluation are grouped the same way.
+			rls, err := cfg.Parse()
+			require.NoError(t, err)
+			expectedGroups := map[string]bool{}
+			for fn, groups := range tc.evaluated {
+				for _, group := range groups {
+					expectedGroups[group+";"+fn] = true
+				}
+			}
+			actualGroups := map[string]bool{}
+			for group := range rls {
+				actualGroups[group] = true
+			}
+			assert.Equal(t, expectedGroups, actualGroups)
+		})
+	}
+}
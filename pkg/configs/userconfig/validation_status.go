@@ -0,0 +1,9 @@
+package userconfig
+
+// ValidationStatus is the result of validating a version of a config when it
+// was set.
+type ValidationStatus struct {
+	Valid    bool     `json:"valid" yaml:"valid"`
+	Error    string   `json:"error,omitempty" yaml:"error,omitempty"`
+	Warnings []string `json:"warnings,omitempty" yaml:"warnings,omitempty"`
+}
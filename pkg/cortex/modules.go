@@ -628,7 +628,11 @@ func (t *Cortex) initConfig() (serv services.Service, err error) {
 		return
 	}
 
-	t.ConfigAPI = configAPI.New(t.ConfigDB, t.Cfg.Configs.API)
+	t.ConfigAPI, err = configAPI.New(t.ConfigDB, t.Cfg.Configs.API)
+	if err != nil {
+		return
+	}
+	t.ConfigAPI.ExternalLabels = t.Cfg.Ruler.ExternalLabels
 	t.ConfigAPI.RegisterRoutes(t.Server.HTTP)
 	return services.NewIdleService(nil, func(_ error) error {
 		t.ConfigDB.Close()
@@ -0,0 +1,156 @@
+package ruler
+
+import (
+	"context"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log/level"
+
+	"github.com/cortexproject/cortex/pkg/configs/userconfig"
+	"github.com/cortexproject/cortex/pkg/util/concurrency"
+)
+
+// RulesEvaluationStatusReporter is implemented by the rule stores keeping
+// track of the health of the rules after their evaluation, such as the config
+// service. The status reported for some rule groups of a user replaces the one
+// previously reported for these groups only, as the rule groups of a user can
+// be evaluated by several rulers.
+type RulesEvaluationStatusReporter interface {
+	SetRulesEvaluationStatus(ctx context.Context, userID string, status userconfig.RulesEvaluationStatus) error
+}
+
+type groupStatusKey struct {
+	file, name string
+}
+
+// reportRulesEvaluationStatusLoop reports the rules evaluation status once per
+// evaluation interval, until the context is done.
+func (r *Ruler) reportRulesEvaluationStatusLoop(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.EvaluationInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.reportRulesEvaluationStatus(ctx)
+		}
+	}
+}
+
+// reportRulesEvaluationStatus reports, for every user, the status of the rule
+// groups evaluated for the first time and of the ones whose health changed
+// since they were last reported. The evaluation times of a group are only
+// reported along with a change of its health.
+func (r *Ruler) reportRulesEvaluationStatus(ctx context.Context) {
+	r.syncedUsersMtx.Lock()
+	users := r.syncedUsers
+	r.syncedUsersMtx.Unlock()
+
+	// Forget about the users whose rules aren't evaluated anymore.
+	synced := make(map[string]struct{}, len(users))
+	for _, userID := range users {
+		synced[userID] = struct{}{}
+	}
+	r.reportedStatusMtx.Lock()
+	for userID := range r.reportedStatus {
+		if _, ok := synced[userID]; !ok {
+			delete(r.reportedStatus, userID)
+		}
+	}
+	r.reportedStatusMtx.Unlock()
+
+	_ = concurrency.ForEachUser(ctx, users, reportStatusConcurrency, func(ctx context.Context, userID string) error {
+		r.reportUserRulesEvaluationStatus(ctx, userID)
+		return nil
+	})
+}
+
+func (r *Ruler) reportUserRulesEvaluationStatus(ctx context.Context, userID string) {
+	r.reportedStatusMtx.Lock()
+	previous := r.reportedStatus[userID]
+	r.reportedStatusMtx.Unlock()
+
+	// Only the groups this ruler still evaluates are kept track of.
+	reported := map[groupStatusKey]userconfig.RuleGroupEvaluationStatus{}
+	var changed []userconfig.RuleGroupEvaluationStatus
+	for _, group := range r.ruleGroupsEvaluationStatus(userID) {
+		if group.LastEvaluation.IsZero() {
+			continue
+		}
+		key := groupStatusKey{file: group.File, name: group.Name}
+		if prev, ok := previous[key]; ok && sameRuleGroupHealth(prev, group) {
+			reported[key] = prev
+			continue
+		}
+		changed = append(changed, group)
+	}
+
+	if len(changed) > 0 {
+		status := userconfig.RulesEvaluationStatus{Groups: changed}
+		if err := r.statusReporter.SetRulesEvaluationStatus(ctx, userID, status); err != nil {
+			// The changed groups are reported again next time.
+			level.Warn(r.logger).Log("msg", "unable to report the rules evaluation status", "user", userID, "err", err)
+		} else {
+			for _, group := range changed {
+				reported[groupStatusKey{file: group.File, name: group.Name}] = group
+			}
+		}
+	}
+
+	r.reportedStatusMtx.Lock()
+	r.reportedStatus[userID] = reported
+	r.reportedStatusMtx.Unlock()
+}
+
+// ruleGroupsEvaluationStatus returns the evaluation status of the rule groups
+// of a user evaluated by this ruler.
+func (r *Ruler) ruleGroupsEvaluationStatus(userID string) []userconfig.RuleGroupEvaluationStatus {
+	groups := r.manager.GetRules(userID)
+	prefix := filepath.Join(r.cfg.RulePath, userID) + "/"
+
+	status := make([]userconfig.RuleGroupEvaluationStatus, 0, len(groups))
+	for _, group := range groups {
+		// The mapped filename is url path escaped encoded to make handling `/` characters easier
+		file, err := url.PathUnescape(strings.TrimPrefix(group.File(), prefix))
+		if err != nil {
+			file = group.File()
+		}
+		groupStatus := userconfig.RuleGroupEvaluationStatus{
+			Name:           group.Name(),
+			File:           file,
+			LastEvaluation: group.GetLastEvaluation(),
+			Rules:          make([]userconfig.RuleEvaluationStatus, 0, len(group.Rules())),
+		}
+		for _, rule := range group.Rules() {
+			ruleStatus := userconfig.RuleEvaluationStatus{
+				Name:           rule.Name(),
+				Health:         string(rule.Health()),
+				LastEvaluation: rule.GetEvaluationTimestamp(),
+			}
+			if err := rule.LastError(); err != nil {
+				ruleStatus.LastError = err.Error()
+			}
+			groupStatus.Rules = append(groupStatus.Rules, ruleStatus)
+		}
+		status = append(status, groupStatus)
+	}
+	return status
+}
+
+// sameRuleGroupHealth returns whether two statuses of a rule group only differ
+// by their evaluation times.
+func sameRuleGroupHealth(a, b userconfig.RuleGroupEvaluationStatus) bool {
+	if a.Name != b.Name || a.File != b.File || len(a.Rules) != len(b.Rules) {
+		return false
+	}
+	for i := range a.Rules {
+		if a.Rules[i].Name != b.Rules[i].Name || a.Rules[i].Health != b.Rules[i].Health || a.Rules[i].LastError != b.Rules[i].LastError {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package ruler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	configAPI "github.com/cortexproject/cortex/pkg/configs/api"
+	"github.com/cortexproject/cortex/pkg/configs/client"
+	"github.com/cortexproject/cortex/pkg/configs/db/memory"
+	"github.com/cortexproject/cortex/pkg/configs/userconfig"
+	"github.com/cortexproject/cortex/pkg/ruler/rulestore/configdb"
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/cortexproject/cortex/pkg/util/test"
+)
+
+func TestRuler_ReportsRulesEvaluationStatus(t *testing.T) {
+	database, err := memory.New("", "")
+	require.NoError(t, err)
+	configs, err := configAPI.New(database, configAPI.Config{})
+	require.NoError(t, err)
+	var reports atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" && r.URL.Path == "/private/api/prom/configs/rules/evaluation-status" {
+			reports.Inc()
+		}
+		configs.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	cfg := userconfig.Config{RulesConfig: userconfig.RulesConfig{
+		FormatVersion: userconfig.RuleFormatV2,
+		Files: map[string]string{
+			"team/rules.yml": `groups:
+- name: group1
+  rules:
+  - record: one
+    expr: vector(1)
+  - record: duplicated
+    expr: label_replace(vector(1) or label_replace(vector(2), "a", "b", "", ""), "a", "", "", "")
+`,
+		},
+	}}
+	body, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	resp := configsRequest(t, server.URL, "POST", "/api/prom/configs/rules", bytes.NewReader(body))
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp.Body.Close()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	store := configdb.NewConfigRuleStore(client.ConfigDBClient{URL: u, Timeout: 5 * time.Second})
+
+	rulerCfg := defaultRulerConfig(t)
+	rulerCfg.EvaluationInterval = 100 * time.Millisecond
+	// The ruler syncs the rules itself when starting: the config rule store
+	// isn't safe for concurrent use.
+	r, _ := buildRuler(t, rulerCfg, nil, store, nil)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), r))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	var status userconfig.RulesEvaluationStatus
+	test.Poll(t, 5*time.Second, true, func() interface{} {
+		resp := configsRequest(t, server.URL, "GET", "/api/prom/configs/rules/evaluation-status", nil)
+		defer resp.Body.Close()
+		status = userconfig.RulesEvaluationStatus{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+		return len(status.Groups) == 1 && !status.Groups[0].LastEvaluation.IsZero()
+	})
+
+	group := status.Groups[0]
+	assert.Equal(t, "group1", group.Name)
+	assert.Equal(t, "team/rules.yml", group.File)
+	require.Len(t, group.Rules, 2)
+
+	assert.Equal(t, "one", group.Rules[0].Name)
+	assert.Equal(t, "ok", group.Rules[0].Health)
+	assert.Empty(t, group.Rules[0].LastError)
+	assert.False(t, group.Rules[0].LastEvaluation.IsZero())
+
+	assert.Equal(t, "duplicated", group.Rules[1].Name)
+	assert.Equal(t, "err", group.Rules[1].Health)
+	assert.Contains(t, group.Rules[1].LastError, "same labelset")
+	assert.False(t, group.Rules[1].LastEvaluation.IsZero())
+
+	// The status is only reported again when the health changes.
+	time.Sleep(5 * rulerCfg.EvaluationInterval)
+	assert.Equal(t, int64(1), reports.Load())
+}
+
+func configsRequest(t *testing.T, serverURL, method, path string, body io.Reader) *http.Response {
+	req, err := http.NewRequest(method, serverURL+path, body)
+	require.NoError(t, err)
+	req.Header.Set("X-Scope-OrgID", "user1")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/weaveworks/common/user"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/cortexproject/cortex/pkg/configs/userconfig"
 	"github.com/cortexproject/cortex/pkg/cortexpb"
 	"github.com/cortexproject/cortex/pkg/ring"
 	"github.com/cortexproject/cortex/pkg/ring/kv"
@@ -56,6 +57,9 @@ const (
 	loadRulesConcurrency  = 10
 	fetchRulesConcurrency = 16
 
+	// Number of users whose rules evaluation status is reported concurrently.
+	reportStatusConcurrency = 10
+
 	rulerSyncReasonInitial    = "initial"
 	rulerSyncReasonPeriodic   = "periodic"
 	rulerSyncReasonRingChange = "ring-change"
@@ -259,6 +263,17 @@ type Ruler struct {
 
 	allowedTenants *util.AllowedTenants
 
+	// statusReporter is the rule store, when it keeps track of the evaluation
+	// status of the rules, nil otherwise.
+	statusReporter RulesEvaluationStatusReporter
+	// syncedUsers are the users whose rules were loaded by the last sync.
+	syncedUsersMtx sync.Mutex
+	syncedUsers    []string
+	// reportedStatus is, by user, the status of each rule group last
+	// reported to the statusReporter.
+	reportedStatusMtx sync.Mutex
+	reportedStatus    map[string]map[groupStatusKey]userconfig.RuleGroupEvaluationStatus
+
 	registry prometheus.Registerer
 	logger   log.Logger
 }
@@ -290,6 +305,11 @@ func newRuler(cfg Config, manager MultiTenantManager, reg prometheus.Registerer,
 		}, []string{"reason"}),
 	}
 
+	if reporter, ok := ruleStore.(RulesEvaluationStatusReporter); ok {
+		ruler.statusReporter = reporter
+		ruler.reportedStatus = map[string]map[groupStatusKey]userconfig.RuleGroupEvaluationStatus{}
+	}
+
 	if len(cfg.EnabledTenants) > 0 {
 		level.Info(ruler.logger).Log("msg", "ruler using enabled users", "enabled", strings.Join(cfg.EnabledTenants, ", "))
 	}
@@ -487,6 +507,19 @@ func (r *Ruler) run(ctx context.Context) error {
 		ringTickerChan = ringTicker.C
 	}
 
+	if r.statusReporter != nil {
+		// Reports are sent apart from this loop, so that they can't delay it.
+		var wg sync.WaitGroup
+		defer wg.Wait()
+		reportCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.reportRulesEvaluationStatusLoop(reportCtx)
+		}()
+	}
+
 	r.syncRules(ctx, rulerSyncReasonInitial)
 	for {
 		select {
@@ -526,6 +559,14 @@ func (r *Ruler) syncRules(ctx context.Context, reason string) {
 
 	// This will also delete local group files for users that are no longer in 'configs' map.
 	r.manager.SyncRuleGroups(ctx, loadedConfigs)
+
+	users := make([]string, 0, len(loadedConfigs))
+	for userID := range loadedConfigs {
+		users = append(users, userID)
+	}
+	r.syncedUsersMtx.Lock()
+	r.syncedUsers = users
+	r.syncedUsersMtx.Unlock()
 }
 
 func (r *Ruler) listRules(ctx context.Context) (result map[string]rulespb.RuleGroupList, err error) {
@@ -45,6 +45,12 @@ func (c *ConfigRuleStore) ListAllUsers(ctx context.Context) ([]string, error) {
 	return result, err
 }
 
+// SetRulesEvaluationStatus reports the health of a user's rules to the config
+// service.
+func (c *ConfigRuleStore) SetRulesEvaluationStatus(ctx context.Context, userID string, status userconfig.RulesEvaluationStatus) error {
+	return c.configClient.SetRulesEvaluationStatus(ctx, userID, status)
+}
+
 // ListAllRuleGroups implements RuleStore
 func (c *ConfigRuleStore) ListAllRuleGroups(ctx context.Context) (map[string]rulespb.RuleGroupList, error) {
 	configs, err := c.configClient.GetRules(ctx, c.since)
@@ -28,6 +28,10 @@ func (c *MockClient) GetAlerts(ctx context.Context, since userconfig.ID) (*clien
 	return nil, nil
 }
 
+func (c *MockClient) SetRulesEvaluationStatus(ctx context.Context, userID string, status userconfig.RulesEvaluationStatus) error {
+	return nil
+}
+
 func Test_ConfigRuleStoreError(t *testing.T) {
 	mock := &MockClient{
 		cfgs: nil,